@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	maxDateDiff  time.Duration
+	maxMtimeDiff time.Duration
+	auditJSON    bool
+)
+
+type auditFile struct {
+	Path   string                     `json:"path"`
+	Date   time.Time                  `json:"date"`
+	Source string                     `json:"source"`
+	Issues []syncmediatrack.DateIssue `json:"issues"`
+}
+
+type auditGroup struct {
+	Camera string      `json:"camera"`
+	Dir    string      `json:"dir"`
+	Files  []auditFile `json:"files"`
+}
+
+var auditDatesCmd = &cobra.Command{
+	Use:   "auditdates",
+	Short: "Find medias with inconsistent dates",
+	Long:  `List the medias whose EXIF, GPS, filename and modification dates disagree, grouped by camera and directory`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if len(args) == 1 {
+			mediaDir = args[0]
+		}
+		auditDatesExecute()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditDatesCmd)
+	rootCmd.PersistentFlags().DurationVar(&maxDateDiff, "maxdiff", 80*time.Second, "Maximum difference between the GPS, EXIF and filename dates of a media")
+	rootCmd.PersistentFlags().DurationVar(&maxMtimeDiff, "maxmtimediff", 30*time.Second, "Maximum difference between the modification time and the other dates of a media")
+	rootCmd.PersistentFlags().BoolVar(&auditJSON, "json", false, "Show the results in JSON format")
+}
+
+func auditDatesExecute() {
+	if auditJSON {
+		// only the results are written to the standard output
+		syncmediatrack.Messages = os.Stderr
+	} else {
+		syncmediatrack.Pass("Reading medias...")
+	}
+
+	groups := map[[2]string]*auditGroup{}
+
+	err := walkMedias(mediaDir, func(path string, relPath string, out io.Writer) {
+		mediaValid.Add(1)
+
+		media, err := syncmediatrack.GetMediaDate(path)
+		if err != nil {
+			mediaError.Add(1)
+			fmt.Fprintf(os.Stderr, "[%v] - %s\n", relPath, err)
+			return
+		}
+
+		issues := syncmediatrack.AuditMediaDate(media, maxDateDiff, maxMtimeDiff)
+		if len(issues) == 0 {
+			return
+		}
+
+		date, source, _ := media.BestInstant()
+
+		key := [2]string{media.Camera.String(), filepath.Dir(relPath)}
+
+		mediaMutex.Lock()
+		defer mediaMutex.Unlock()
+
+		if groups[key] == nil {
+			groups[key] = &auditGroup{Camera: key[0], Dir: key[1]}
+		}
+		groups[key].Files = append(groups[key].Files, auditFile{Path: relPath, Date: date, Source: source, Issues: issues})
+	})
+	if err != nil {
+		syncmediatrack.Warning(err.Error())
+	}
+
+	result := make([]auditGroup, 0, len(groups))
+	for _, group := range groups {
+		// the medias are read at the same time
+		sort.Slice(group.Files, func(i, j int) bool {
+			return group.Files[i].Path < group.Files[j].Path
+		})
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Camera != result[j].Camera {
+			return result[i].Camera < result[j].Camera
+		}
+		return result[i].Dir < result[j].Dir
+	})
+
+	if auditJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			syncmediatrack.Error(err.Error())
+		}
+		return
+	}
+
+	syncmediatrack.Pass("Inconsistent dates...")
+
+	inconsistent := 0
+	for _, group := range result {
+		fmt.Printf("%s %s\n", syncmediatrack.ColorBlue(group.Camera), group.Dir)
+
+		for _, file := range group.Files {
+			inconsistent++
+			fmt.Printf("  [%v] - %s (%s)", filepath.Base(file.Path), file.Date.Format("02/01/2006 15:04:05"), file.Source)
+
+			for _, issue := range file.Issues {
+				fmt.Printf(" %s %s %s", issue.Source, syncmediatrack.ColorYellow(issue.Date.Format("02/01/2006 15:04:05")), time.Duration(issue.Diff))
+				if issue.Pattern != "" {
+					fmt.Printf(" %s", syncmediatrack.ColorRed("("+issue.Pattern+")"))
+				}
+			}
+			fmt.Println()
+		}
+	}
+
+	if mediaError.Load() == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Processed %d media(s), %d with inconsistent dates\n"), mediaValid.Load(), inconsistent)
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Processed %d media(s), %d with inconsistent dates, %d with error(s)\n"), mediaValid.Load(), inconsistent, mediaError.Load())
+	}
+}
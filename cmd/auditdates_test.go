@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+)
+
+func TestAuditDatesJSON(t *testing.T) {
+	dir := t.TempDir()
+
+	// a file whose content can't be read to know if it is a media
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "unreadable.dat")); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, messages := os.Stdout, os.Stderr, syncmediatrack.Messages
+	defer func() {
+		os.Stdout, os.Stderr, syncmediatrack.Messages = stdout, stderr, messages
+		auditJSON, mediaDir = false, ""
+		mediaError.Store(0)
+		mediaValid.Store(0)
+	}()
+
+	outReader, outWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errReader, errWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr, syncmediatrack.Messages = outWriter, errWriter, outWriter
+
+	auditJSON, mediaDir = true, dir
+	auditDatesExecute()
+
+	outWriter.Close()
+	errWriter.Close()
+	os.Stdout, os.Stderr = stdout, stderr
+
+	output, err := io.ReadAll(outReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diagnostics, err := io.ReadAll(errReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []auditGroup
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Unexpected error %v decoding %q", err, output)
+	}
+	if len(result) != 0 {
+		t.Errorf("Expected no medias, got %+v", result)
+	}
+
+	if !strings.Contains(string(diagnostics), "unreadable.dat") {
+		t.Errorf("Expected the warning of unreadable.dat in the standard error, got %q", diagnostics)
+	}
+	if mediaError.Load() != 1 {
+		t.Errorf("Expected 1 error, got %d", mediaError.Load())
+	}
+}
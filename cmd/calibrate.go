@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	saveProfile   string
+	maxDistance   float64
+	minConfidence float64
+
+	// Only the track points this time around the camera time are checked
+	calibrateWindow = 12 * time.Hour
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Estimate the camera clock error",
+	Long:  `Using a gpx track and the medias that already have GPS position, estimate the clock error of each camera`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if len(args) == 1 {
+			mediaDir = args[0]
+		}
+		calibrateExecute()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+	rootCmd.PersistentFlags().StringVar(&saveProfile, "saveprofile", "", "Save the estimated clock errors in this JSON file of camera profiles")
+	rootCmd.PersistentFlags().Float64Var(&maxDistance, "maxdistance", 50, "Maximum distance in meters between the media and the track to use it in the calibration")
+	rootCmd.PersistentFlags().Float64Var(&minConfidence, "minconfidence", 0.5, "Minimum confidence to save the estimated clock error of a camera")
+}
+
+func calibrateExecute() {
+	syncmediatrack.ReadTracks(track, true)
+
+	syncmediatrack.Pass("Reading medias...")
+
+	offsets := map[syncmediatrack.Camera][]time.Duration{}
+	usedProfiles := map[syncmediatrack.Camera]*syncmediatrack.CameraProfile{}
+
+	err := walkMedias(mediaDir, func(path string, relPath string, out io.Writer) {
+		var location syncmediatrack.Trkpt
+
+		mediaValid.Add(1)
+
+		media, err := syncmediatrack.GetMediaDate(path)
+		if err != nil {
+			mediaError.Add(1)
+			fmt.Fprintf(out, "[%v] - %s\n", relPath, err)
+			return
+		}
+
+		if media.GPS.Lat == 0 && media.GPS.Lon == 0 {
+			return
+		}
+
+		cameraTime := media.Etime
+		if cameraTime.IsZero() {
+			cameraTime = media.Atime
+		}
+
+		fmt.Fprintf(out, "[%v] - %s - %s ", relPath, media.Camera, cameraTime.Format("02/01/2006 15:04:05"))
+
+		distance, ok := syncmediatrack.GetClosestPosition(media.GPS.Lat, media.GPS.Lon, cameraTime, calibrateWindow, &location)
+		if !ok || distance > maxDistance {
+			fmt.Fprintln(out, syncmediatrack.ColorRed("(There is no close position in the track)"))
+			return
+		}
+
+		trackTime := syncmediatrack.GetTimeFromTrkpt(location)
+		offset := cameraTime.Sub(trackTime)
+
+		fmt.Fprintf(out, "-> %s (%.0f m) Offset %s\n", syncmediatrack.TrkptLocalTime(location).Format("02/01/2006 15:04:05"), distance, offset)
+
+		mediaMutex.Lock()
+		offsets[media.Camera] = append(offsets[media.Camera], offset)
+		usedProfiles[media.Camera] = media.Profile
+		mediaMutex.Unlock()
+	})
+	if err != nil {
+		syncmediatrack.Warning(err.Error())
+	}
+
+	syncmediatrack.Pass("Clock errors...")
+
+	cameras := make([]syncmediatrack.Camera, 0, len(offsets))
+	for camera := range offsets {
+		cameras = append(cameras, camera)
+	}
+	sort.Slice(cameras, func(i, j int) bool {
+		return cameras[i].String() < cameras[j].String()
+	})
+
+	for _, camera := range cameras {
+		estimate := syncmediatrack.EstimateOffset(offsets[camera])
+
+		fmt.Printf("[%v] - Offset %s ±%s (%d of %d medias) confidence %.2f ",
+			camera, estimate.Offset, estimate.Spread, estimate.Inliers, estimate.Samples, estimate.Confidence)
+
+		if saveProfile == "" {
+			fmt.Println()
+			continue
+		}
+
+		if estimate.Confidence < minConfidence {
+			fmt.Println(syncmediatrack.ColorYellow("(low confidence, not saved)"))
+			continue
+		}
+
+		profile := calibratedProfile(camera, usedProfiles[camera], estimate.Offset)
+
+		if dryRun {
+			fmt.Println()
+			continue
+		}
+
+		err := syncmediatrack.SaveCameraProfile(saveProfile, profile)
+		if err != nil {
+			fmt.Println(syncmediatrack.ColorRed(err))
+			continue
+		}
+
+		fmt.Println(syncmediatrack.ColorGreen("(saved)"))
+	}
+
+	if mediaError.Load() == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Processed %d media(s)\n"), mediaValid.Load())
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Processed %d media(s), %d with error(s)\n"), mediaValid.Load(), mediaError.Load())
+	}
+}
+
+// calibratedProfile returns the profile of the camera with the estimated error added to the
+// error already corrected by the profile used to read the medias
+func calibratedProfile(camera syncmediatrack.Camera, used *syncmediatrack.CameraProfile, offset time.Duration) syncmediatrack.CameraProfile {
+	profile := syncmediatrack.CameraProfile{
+		Make:   camera.Make,
+		Model:  camera.Model,
+		Serial: camera.Serial,
+	}
+
+	if used != nil {
+		profile.Name = used.Name
+		profile.TimeZone = used.TimeZone
+		profile.Offset = used.Offset
+		profile.Drift = used.Drift
+		profile.Reference = used.Reference
+	}
+
+	profile.Offset += syncmediatrack.Duration(offset)
+
+	return profile
+}
@@ -2,29 +2,83 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
-	"github.com/karrick/godirwalk"
 	"github.com/spf13/cobra"
 )
 
 type ImageInfo struct {
-	Path         string
-	atime        time.Time
-	etime        time.Time
-	gtime        time.Time
+	Path  string
+	atime time.Time
+	etime time.Time
+	gtime time.Time
+	ftime time.Time
+	// stime is etime before the correction of the profile
+	stime time.Time
+	// naive is true when the camera time is a wall clock without time zone, it is interpreted in zone
+	naive bool
+	zone  *time.Location
+	// dateOnly is true when the camera time is the day given by the filename
+	dateOnly     bool
 	HasGPSDate   bool
 	AdjustedDate time.Time
 	IsAdjusted   bool
+	InferredDate time.Time
+	IsInferred   bool
+}
+
+// CameraTime returns the time given by the camera clock, or the time inferred from the
+// neighbours when it is out of sequence
+func (i ImageInfo) CameraTime() time.Time {
+	if i.IsInferred {
+		return i.InferredDate
+	}
+	if !i.etime.IsZero() {
+		return i.etime
+	}
+	if !i.ftime.IsZero() {
+		return i.ftime
+	}
+	return i.atime
+}
+
+// cameraInstant returns the camera time as an instant, a time without time zone is interpreted in
+// the time zone of the camera
+func (i ImageInfo) cameraInstant() time.Time {
+	return i.instant(i.CameraTime())
+}
+
+// storedInstant returns the date stored by the camera as an instant, without the correction of
+// the profile
+func (i ImageInfo) storedInstant() time.Time {
+	return i.instant(i.stime)
+}
+
+// writable checks if there is a date to write in the media: the dates of the metadata, the date of
+// the filename or the modification time with --updatemtime
+func (i ImageInfo) writable() bool {
+	return i.IsInferred || !i.etime.IsZero() || (!i.ftime.IsZero() && !i.dateOnly) || updateMtime
+}
+
+func (i ImageInfo) instant(t time.Time) time.Time {
+	if i.naive && i.zone != nil {
+		return syncmediatrack.WallTime(t, i.zone)
+	}
+
+	return t
 }
 
 type Segment struct {
 	Diff      time.Duration
+	Estimate  syncmediatrack.OffsetEstimate
+	Drift     syncmediatrack.DriftEstimate
 	StartTime time.Time
 	EndTime   time.Time
 	ID        []string
@@ -34,6 +88,13 @@ var (
 	MaxTimeSegment float64 = 60 * 4 // 4 hours
 	imageFile              = map[string][]ImageInfo{}
 	DenyExtension          = []string{"LRV", "THM"}
+	updateMtime    bool
+	keepMtime      bool
+
+	// Clock errors smaller than this are not corrected
+	MinClockError = time.Second
+	// Residuals of the clock error fit greater than this are shown as a warning
+	MaxResidual = 30 * time.Second
 )
 
 var fixTimeCmd = &cobra.Command{
@@ -48,18 +109,11 @@ var fixTimeCmd = &cobra.Command{
 	},
 }
 
-func GetTime(atime time.Time, etime time.Time, gtime time.Time) time.Time {
-	if !gtime.IsZero() {
-		return gtime
-	}
-	if !etime.IsZero() {
-		return gtime
-	}
-	return atime
-}
-
 func init() {
 	rootCmd.AddCommand(fixTimeCmd)
+	rootCmd.PersistentFlags().BoolVar(&updateMtime, "updatemtime", false, "Update the modification time of the files with the corrected date or the date of the media")
+	rootCmd.PersistentFlags().BoolVar(&keepMtime, "keepmtime", false, "Keep the modification time of the files when their metadata is written")
+	rootCmd.PersistentFlags().BoolVar(&syncmediatrack.InferOutOfSequence, "fixoutofsequence", false, "Replace also the valid dates far from the dates of the medias with the previous and next file number")
 
 	imageFile = make(map[string][]ImageInfo)
 }
@@ -68,87 +122,75 @@ func fixTimeExecute() {
 	syncmediatrack.Pass("Reading medias...")
 	syncmediatrack.Pass("First pass...")
 
-	re := regexp.MustCompile(`([A-Za-z-_]*)(\d[\w.-]*)(\.[\w]+)$`)
-	var split []string
-	var id string
-	var src time.Time
-
-	err := godirwalk.Walk(mediaDir, &godirwalk.Options{
-		Callback: func(path string, de *godirwalk.Dirent) error {
-			var gpsOld syncmediatrack.Trkpt
-
-			if de.IsDir() {
-				return nil // do not remove directory that was provided top-level directory
-			}
-
-			if !syncmediatrack.FileIsMedia(path) {
-				return nil
-			}
-
-			relPath, err := filepath.Rel(mediaDir, path)
-			if err != nil {
-				mediaError++
-				return err
+	err := walkMedias(mediaDir, func(path string, relPath string, out io.Writer) {
+		// Exclude extension to analyze
+		for _, ext := range DenyExtension {
+			if filepath.Ext(path) == "."+ext {
+				return
 			}
+		}
 
-			// Exclude extension to analyze
-			for _, ext := range DenyExtension {
-				if filepath.Ext(path) == "."+ext {
-					return nil
-				}
-			}
+		media, err := syncmediatrack.GetMediaDate(path)
+		if err != nil {
+			mediaError.Add(1)
+			fmt.Fprintln(out, err)
+			return
+		}
+		atime, etime, gtime, ftime := media.Atime, media.Etime, media.Gtime, media.Ftime
 
-			atime, etime, gtime, err := syncmediatrack.GetMediaDate(path, &gpsOld)
-			if err != nil {
-				mediaError++
-				fmt.Println(err)
-				return nil
-			}
+		name, ok := syncmediatrack.ParseFileName(relPath)
+		mediaValid.Add(1)
+		fmt.Fprintf(out, "[%v] - ", relPath)
 
-			split = re.FindStringSubmatch(relPath)
-			mediaValid++
-			fmt.Printf("[%v] - ", relPath)
+		if !ok {
+			mediaError.Add(1)
+			fmt.Fprintln(out, " - Error: Can't get file ID")
+			return
+		}
+		id := name.ID
 
-			if len(split) < 2 {
-				mediaError++
-				fmt.Println(" - Error: Can't get file ID")
-				return nil
-			}
+		fmt.Fprintf(out, " ID: %s A: %s E: %s G: %s",
+			id,
+			atime.Format("02/01/2006 15:04:05"),
+			etime.Format("02/01/2006 15:04:05"),
+			gtime.Format("02/01/2006 15:04:05"),
+		)
 
-			id = split[2]
-			// If the file start with GL o GX (GOPRO Files) remove 2 first characters from id
-			if split[1] == "GL" || split[1] == "GX" {
-				id = id[2:]
-			}
+		if !ftime.IsZero() {
+			fmt.Fprintf(out, " F: %s", ftime.Format("02/01/2006 15:04:05"))
+		}
 
-			fmt.Printf(" ID: %s A: %s E: %s G: %s",
-				id,
-				atime.Format("02/01/2006 15:04:05"),
-				etime.Format("02/01/2006 15:04:05"),
-				gtime.Format("02/01/2006 15:04:05"),
-			)
-
-			if !etime.IsZero() {
-				src = etime
-			} else {
-				src = atime
-			}
+		fmt.Fprintf(out, " (%s)", name.Scheme)
 
-			if !gtime.IsZero() {
-				// diff times and adjust
-				diff := gtime.Sub(src)
-				fmt.Printf(" Diff: %s", diff.String())
-			}
+		if media.Profile != nil {
+			fmt.Fprintf(out, " Profile: %s", syncmediatrack.ColorBlue(media.Profile))
+		}
 
-			fmt.Println()
+		_, naive := media.CameraDate()
+		info := ImageInfo{
+			Path:       path,
+			atime:      atime,
+			etime:      etime,
+			gtime:      gtime,
+			ftime:      ftime,
+			stime:      media.Stime,
+			naive:      naive,
+			zone:       media.Zone,
+			dateOnly:   etime.IsZero() && !ftime.IsZero() && media.FtimeDateOnly,
+			HasGPSDate: !gtime.IsZero(),
+		}
 
-			imageFile[id] = append(imageFile[id], ImageInfo{Path: path, atime: atime, etime: etime, gtime: gtime})
+		if !gtime.IsZero() && !info.dateOnly && (!naive || info.zone != nil) {
+			// camera clock error, positive when the camera is ahead
+			diff := info.cameraInstant().Sub(gtime)
+			fmt.Fprintf(out, " Diff: %s", diff.String())
+		}
 
-			mediaUpdate++
+		fmt.Fprintln(out)
 
-			return nil
-		},
-		Unsorted: false,
+		mediaMutex.Lock()
+		imageFile[id] = append(imageFile[id], info)
+		mediaMutex.Unlock()
 	})
 	if err != nil {
 		syncmediatrack.Warning(err.Error())
@@ -159,57 +201,350 @@ func fixTimeExecute() {
 	var ids []string
 	for k := range imageFile {
 		ids = append(ids, k)
+
+		// the medias are read at the same time
+		sort.Slice(imageFile[k], func(i, j int) bool {
+			return imageFile[k][i].Path < imageFile[k][j].Path
+		})
 	}
 
 	sort.Strings(ids)
 
-	for _, k := range ids {
-		fmt.Printf("Clave: %s\n", k)
-		for _, value1 := range imageFile[k] {
-			// Show path and date from ImageInfo
-			fmt.Printf("Path: %s\n", value1.Path)
+	if syncmediatrack.Verbose {
+		for _, k := range ids {
+			fmt.Printf("ID: %s\n", k)
+			for _, value1 := range imageFile[k] {
+				// Show path and date from ImageInfo
+				fmt.Printf("Path: %s\n", value1.Path)
+			}
+		}
+	}
+
+	syncmediatrack.Pass("Sequence...")
+
+	inferImageTimes()
+
+	seg := getSegments(ids)
+
+	syncmediatrack.Pass("Plan...")
+
+	for key := range seg {
+		getSegmentDiff(&seg[key])
+		showSegment(key, seg[key])
+	}
+
+	if !dryRun {
+		syncmediatrack.Pass("Updating medias...")
+	}
+
+	var paths []string
+	adjusted := map[string]ImageInfo{}
+	for _, value := range seg {
+		for _, k := range value.ID {
+			for _, info := range imageFile[k] {
+				if !info.IsAdjusted {
+					continue
+				}
+
+				if dryRun {
+					mediaUpdate.Add(1)
+					continue
+				}
+
+				paths = append(paths, info.Path)
+				adjusted[info.Path] = info
+			}
+		}
+	}
+
+	processMedias(paths, func(path string, out io.Writer) {
+		updateMediaTime(out, adjusted[path])
+	})
+
+	if mediaError.Load() == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Processed %d media(s)\n"), mediaValid.Load())
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Processed %d media(s), %d with error(s)\n"), mediaValid.Load(), mediaError.Load())
+	}
+
+	fmt.Printf(syncmediatrack.ColorGreen("Updated %d media(s) with the corrected time\n"), mediaUpdate.Load())
+}
+
+// inferImageTimes estimates the time of the medias out of sequence with their neighbours
+func inferImageTimes() {
+	var items []syncmediatrack.SequenceItem
+
+	for _, infos := range imageFile {
+		for _, info := range infos {
+			item, ok := syncmediatrack.NewSequenceItem(info.Path, info.CameraTime())
+			if ok {
+				items = append(items, item)
+			}
 		}
 	}
 
+	syncmediatrack.InferSequenceTimes(items)
+
+	inferred := map[string]syncmediatrack.SequenceItem{}
+	for _, item := range items {
+		if !item.OutOfSequence {
+			continue
+		}
+
+		inferred[item.Path] = item
+
+		relPath, err := filepath.Rel(mediaDir, item.Path)
+		if err != nil {
+			relPath = item.Path
+		}
+
+		fmt.Printf("[%v] - %s ", relPath, item.Time.Format("02/01/2006 15:04:05"))
+		switch {
+		case item.Inferred:
+			fmt.Printf("-> %s %s\n", item.InferredTime.Format("02/01/2006 15:04:05"), syncmediatrack.ColorYellow("(inferred)"))
+		case !syncmediatrack.DateIsReset(item.Time) && !syncmediatrack.InferOutOfSequence:
+			fmt.Println(syncmediatrack.ColorYellow("(out of sequence, use --fixoutofsequence to replace the date)"))
+		default:
+			fmt.Println(syncmediatrack.ColorRed("(out of sequence, the time can't be inferred)"))
+		}
+	}
+
+	for k := range imageFile {
+		for i := range imageFile[k] {
+			item, ok := inferred[imageFile[k][i].Path]
+			if ok && item.Inferred {
+				imageFile[k][i].IsInferred = true
+				imageFile[k][i].InferredDate = item.InferredTime
+			}
+		}
+	}
+}
+
+// getSegments splits the medias ordered by ID when the camera time jumps backwards or more than
+// MaxTimeSegment minutes
+func getSegments(ids []string) []Segment {
 	seg := []Segment{}
 	oldTime := time.Time{}
 	ID := []string{}
 	StoredTime := time.Time{}
 	StartTime := time.Time{}
 	EndTime := time.Time{}
-	Diff := time.Duration(0)
 
 	for _, k := range ids {
-		StoredTime = GetTime(imageFile[k][0].atime, imageFile[k][0].etime, imageFile[k][0].etime)
+		StoredTime = imageFile[k][0].CameraTime()
 		if (oldTime != time.Time{}) {
 			t := StoredTime.Sub(oldTime)
 			if math.Abs(t.Minutes()) > MaxTimeSegment || oldTime.After(StoredTime) {
-				seg = append(seg, Segment{Diff: Diff, StartTime: StartTime, EndTime: EndTime, ID: ID})
+				seg = append(seg, Segment{StartTime: StartTime, EndTime: EndTime, ID: ID})
 				ID = []string{}
-				oldTime = time.Time{}
 				StartTime = StoredTime
 			}
 		} else {
 			StartTime = StoredTime
 		}
 		oldTime = StoredTime
+		EndTime = StoredTime
 		ID = append(ID, k)
-		if !imageFile[k][0].gtime.IsZero() {
-			Diff1 := StoredTime.Sub(imageFile[k][0].gtime)
-			Diff = (Diff + Diff1) / 2
-		}
 	}
 	if len(ID) > 0 {
-		seg = append(seg, Segment{Diff: Diff, StartTime: StartTime, EndTime: EndTime, ID: ID})
+		seg = append(seg, Segment{StartTime: StartTime, EndTime: EndTime, ID: ID})
+	}
+
+	return seg
+}
+
+// getSegmentDiff estimates the camera clock error of the segment from the medias with GPS time,
+// with enough medias the drift of the clock is also estimated. The medias without time zone get
+// the time zone of the first media of the segment whose time zone is known
+func getSegmentDiff(segment *Segment) {
+	var times []time.Time
+	var offsets []time.Duration
+	var zone *time.Location
+
+	for _, k := range segment.ID {
+		for _, info := range imageFile[k] {
+			if zone == nil && info.zone != nil {
+				zone = info.zone
+			}
+		}
+	}
+
+	for _, k := range segment.ID {
+		for i := range imageFile[k] {
+			info := &imageFile[k][i]
+			if info.naive && info.zone == nil {
+				info.zone = zone
+			}
+
+			// the wall clock of a camera in an unknown time zone can't be compared with the GPS time
+			if info.HasGPSDate && !info.dateOnly && (!info.naive || info.zone != nil) {
+				times = append(times, info.cameraInstant())
+				offsets = append(offsets, info.cameraInstant().Sub(info.gtime))
+			}
+		}
+	}
+
+	segment.Estimate = syncmediatrack.EstimateOffset(offsets)
+	segment.Drift = syncmediatrack.EstimateDrift(times, offsets)
+	segment.Diff = segment.Drift.Offset
+
+	for _, k := range segment.ID {
+		for i := range imageFile[k] {
+			info := &imageFile[k][i]
+			diff := segment.Drift.At(info.cameraInstant())
+			info.AdjustedDate = info.cameraInstant().Add(-diff)
+
+			// the stored dates also need the correction of the profile
+			shift := diff
+			if !info.etime.IsZero() && !info.IsInferred {
+				shift = info.storedInstant().Sub(info.AdjustedDate)
+			}
+
+			// the day of the filename has no time to correct
+			info.IsAdjusted = info.writable() && (info.IsInferred || (segment.Estimate.Samples > 0 && !info.dateOnly && absDuration(shift) >= MinClockError))
+		}
+	}
+}
+
+func showSegment(key int, segment Segment) {
+	fmt.Printf("Segment: %d\n", key)
+	fmt.Printf("Start: %s\n", segment.StartTime.Format("02/01/2006 15:04:05"))
+	fmt.Printf("End: %s\n", segment.EndTime.Format("02/01/2006 15:04:05"))
+
+	if segment.Estimate.Samples == 0 {
+		fmt.Println(syncmediatrack.ColorYellow("Diff: no media with GPS time"))
+	} else {
+		fmt.Printf("Diff: %s", segment.Diff)
+		if segment.Drift.Drift != 0 {
+			fmt.Printf(" at %s, drift %s per day", segment.Drift.Reference.Format("02/01/2006 15:04:05"), segment.Drift.Drift)
+		}
+		fmt.Printf(" (%d media(s) with GPS time)\n", segment.Estimate.Samples)
+
+		residuals := fmt.Sprintf("Residuals: max %s, median %s", segment.Drift.MaxResidual, segment.Drift.Spread)
+		if segment.Drift.MaxResidual > MaxResidual {
+			fmt.Println(syncmediatrack.ColorYellow(residuals))
+		} else {
+			fmt.Println(residuals)
+		}
+	}
+
+	for _, k := range segment.ID {
+		for _, info := range imageFile[k] {
+			if segment.Estimate.Samples == 0 && !info.IsAdjusted {
+				continue
+			}
+
+			relPath, err := filepath.Rel(mediaDir, info.Path)
+			if err != nil {
+				relPath = info.Path
+			}
+
+			fmt.Printf("[%v] - %s", relPath, info.CameraTime().Format("02/01/2006 15:04:05"))
+			if info.IsAdjusted {
+				fmt.Printf(" -> %s", info.AdjustedDate.Format("02/01/2006 15:04:05"))
+			}
+			if info.IsInferred {
+				fmt.Printf(" %s", syncmediatrack.ColorYellow("(inferred)"))
+			}
+			if info.HasGPSDate {
+				fmt.Printf(" [G] %s residual %s", info.gtime.Format("02/01/2006 15:04:05"), info.AdjustedDate.Sub(info.gtime))
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// updateMediaTime writes the corrected dates of the media and its companion files
+func updateMediaTime(out io.Writer, info ImageInfo) {
+	relPath, err := filepath.Rel(mediaDir, info.Path)
+	if err != nil {
+		relPath = info.Path
+	}
+
+	fmt.Fprintf(out, "[%v] - ", relPath)
+
+	written := updateMtime
+	for _, path := range append([]string{info.Path}, companions[info.Path]...) {
+		tags, err := writeMediaTime(info, path)
+		if err != nil {
+			mediaError.Add(1)
+			fmt.Fprintln(out, syncmediatrack.ColorRed(err))
+			return
+		}
+
+		if path == info.Path && len(tags) > 0 {
+			written = true
+			fmt.Fprintf(out, "%s ", strings.Join(tags, ", "))
+		}
+	}
+
+	if !written {
+		fmt.Fprintln(out, syncmediatrack.ColorYellow("(no date to update)"))
+		return
+	}
+
+	if updateMtime {
+		fmt.Fprintf(out, "mtime ")
 	}
 
-	for key, value := range seg {
-		fmt.Printf("Segment: %d\n", key)
-		fmt.Printf("Start: %s\n", value.StartTime.Format("02/01/2006 15:04:05"))
-		fmt.Printf("End: %s\n", value.EndTime.Format("02/01/2006 15:04:05"))
-		fmt.Printf("Diff: %s\n", value.Diff.String())
-		for _, value1 := range value.ID {
-			fmt.Printf("ID: %s\n", value1)
+	if len(companions[info.Path]) > 0 {
+		fmt.Fprintf(out, "+%d companion file(s) ", len(companions[info.Path]))
+	}
+
+	mediaUpdate.Add(1)
+
+	fmt.Fprintln(out, syncmediatrack.ColorGreen("(updating)"))
+}
+
+// writeMediaTime writes the corrected dates of the media in the file, a companion file is
+// corrected as its media
+func writeMediaTime(info ImageInfo, path string) ([]string, error) {
+	var tags []string
+
+	err := writeWithMtime(path, info.AdjustedDate, func() error {
+		var err error
+		switch {
+		case info.IsInferred || (info.etime.IsZero() && !info.ftime.IsZero() && !info.dateOnly):
+			// there are no dates in the metadata to shift
+			tags, err = syncmediatrack.SetMediaDates(path, info.AdjustedDate, info.zone)
+		case !info.etime.IsZero():
+			// from the stored dates to the corrected instant, including the correction of the profile
+			tags, err = syncmediatrack.ShiftMediaDates(path, info.storedInstant().Sub(info.AdjustedDate), info.zone)
 		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// writeWithMtime calls write and then keeps the modification time of the file with --keepmtime or
+// sets it to the date of the media with --updatemtime
+func writeWithMtime(path string, date time.Time, write func() error) error {
+	f, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	switch {
+	case updateMtime:
+		return syncmediatrack.SetFileTime(path, date)
+	case keepMtime:
+		return syncmediatrack.SetFileTime(path, f.ModTime())
+	}
+
+	return nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
 	}
+	return d
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetSegmentDiffWritable(t *testing.T) {
+	defer func() {
+		imageFile = map[string][]ImageInfo{}
+		updateMtime = false
+	}()
+
+	// the camera is 10 minutes ahead
+	gtime := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	etime := gtime.Add(10 * time.Minute)
+
+	tests := []struct {
+		name        string
+		info        ImageInfo
+		updateMtime bool
+		expected    bool
+	}{
+		{"exif", ImageInfo{Path: "b", etime: etime.Add(time.Minute), stime: etime.Add(time.Minute)}, false, true},
+		{"filename", ImageInfo{Path: "b", ftime: etime.Add(time.Minute)}, false, true},
+		{"filename with only the day", ImageInfo{Path: "b", ftime: time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC), dateOnly: true}, false, false},
+		{"modification time", ImageInfo{Path: "b", atime: etime.Add(time.Minute)}, false, false},
+		{"modification time with updatemtime", ImageInfo{Path: "b", atime: etime.Add(time.Minute)}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updateMtime = tt.updateMtime
+			imageFile = map[string][]ImageInfo{
+				"a": {{Path: "a", etime: etime, stime: etime, gtime: gtime, HasGPSDate: true}},
+				"b": {tt.info},
+			}
+
+			segment := Segment{ID: []string{"a", "b"}}
+			getSegmentDiff(&segment)
+
+			if imageFile["b"][0].IsAdjusted != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, imageFile["b"][0].IsAdjusted)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/spf13/cobra"
+	gogpx "github.com/twpayne/go-gpx"
+)
+
+var (
+	trackOutput string
+	segmentGap  time.Duration
+)
+
+var mediaToTrackCmd = &cobra.Command{
+	Use:   "mediatotrack",
+	Short: "Create a GPX track from the medias",
+	Long:  `Analyze a directory with images or movies and create a GPX track with the GPS positions of the medias`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if len(args) == 1 {
+			mediaDir = args[0]
+		}
+		mediaToTrackExecute()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mediaToTrackCmd)
+	rootCmd.PersistentFlags().StringVar(&trackOutput, "output", "", "GPX file to write the track created from the medias")
+	rootCmd.PersistentFlags().DurationVar(&segmentGap, "segmentgap", 30*time.Minute, "Start a new track segment when the time between two medias is greater")
+}
+
+func mediaToTrackExecute() {
+	if trackOutput == "" {
+		syncmediatrack.Error("The output GPX file is required (--output)")
+		return
+	}
+
+	if _, err := os.Stat(trackOutput); err == nil && !force {
+		syncmediatrack.Error(fmt.Sprintf("%s already exists, use --force to overwrite it", trackOutput))
+		return
+	}
+
+	syncmediatrack.Pass("Reading medias...")
+
+	medias := readGeotaggedMedias(mediaDir)
+	if len(medias) == 0 {
+		syncmediatrack.Warning("There is no media with GPS position")
+		return
+	}
+
+	syncmediatrack.SortGeoMedias(medias)
+	segments := syncmediatrack.SplitSegments(medias, segmentGap)
+
+	syncmediatrack.Pass("Track...")
+
+	trk := &gogpx.TrkType{Name: filepath.Base(mediaDir), Src: "SyncMediaTrack"}
+	for _, segment := range segments {
+		first, last := segment[0], segment[len(segment)-1]
+		fmt.Printf("%s -> %s %d point(s)\n", first.Time.Format("02/01/2006 15:04:05"), last.Time.Format("02/01/2006 15:04:05"), len(segment))
+
+		trkseg := &gogpx.TrkSegType{}
+		for _, media := range segment {
+			trkseg.TrkPt = append(trkseg.TrkPt, &gogpx.WptType{
+				Lat:  media.GPS.Lat,
+				Lon:  media.GPS.Lon,
+				Ele:  media.GPS.Ele,
+				Time: media.Time.UTC(),
+				Name: media.Path,
+			})
+		}
+		trk.TrkSeg = append(trk.TrkSeg, trkseg)
+	}
+
+	if !dryRun {
+		err := writeGPX(trackOutput, &gogpx.GPX{Version: "1.1", Creator: "SyncMediaTrack", Trk: []*gogpx.TrkType{trk}})
+		if err != nil {
+			syncmediatrack.Error(err.Error())
+			return
+		}
+	}
+
+	fmt.Printf(syncmediatrack.ColorGreen("Created %s with %d point(s) in %d segment(s)\n"), trackOutput, len(medias), len(segments))
+}
+
+// readGeotaggedMedias returns the medias of the directory with GPS position and their best date
+func readGeotaggedMedias(dir string) []syncmediatrack.GeoMedia {
+	var medias []syncmediatrack.GeoMedia
+
+	err := walkMedias(dir, func(path string, relPath string, out io.Writer) {
+		media, err := syncmediatrack.GetMediaDate(path)
+		if err != nil {
+			fmt.Fprintf(out, "[%v] - %s\n", relPath, err)
+			return
+		}
+
+		if media.GPS.Lat == 0 && media.GPS.Lon == 0 {
+			return
+		}
+
+		date, source, ok := media.BestInstant()
+		if !ok {
+			// the time of the point would be wrong by the offset of the time zone
+			fmt.Fprintf(out, "[%v] - %s (%s) %s\n", relPath, date.Format("02/01/2006 15:04:05"), source, syncmediatrack.ColorYellow("(the time zone or the time of the day is unknown, skipped)"))
+			return
+		}
+
+		if syncmediatrack.Verbose {
+			fmt.Fprintf(out, "[%v] - %s (%s) Lat %v Lon %v Ele %v\n", relPath, date.Format("02/01/2006 15:04:05"), source, media.GPS.Lat, media.GPS.Lon, media.GPS.Ele)
+		}
+
+		mediaMutex.Lock()
+		medias = append(medias, syncmediatrack.GeoMedia{Path: relPath, Time: date, GPS: media.GPS})
+		mediaMutex.Unlock()
+	})
+	if err != nil {
+		syncmediatrack.Warning(err.Error())
+	}
+
+	return medias
+}
+
+// tracksToGPX returns the tracks with a track for each file
+func tracksToGPX(data map[string]syncmediatrack.Gpx) *gogpx.GPX {
+	filenames := make([]string, 0, len(data))
+	for filename := range data {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	g := &gogpx.GPX{Version: "1.1", Creator: "SyncMediaTrack"}
+	for _, filename := range filenames {
+		trkseg := &gogpx.TrkSegType{}
+		for _, trkpt := range data[filename].Trk.Trkseg.Trkpt {
+			trkseg.TrkPt = append(trkseg.TrkPt, &gogpx.WptType{
+				Lat:  trkpt.Lat,
+				Lon:  trkpt.Lon,
+				Ele:  trkpt.Ele,
+				Time: syncmediatrack.GetTimeFromTrkpt(trkpt),
+			})
+		}
+
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		g.Trk = append(g.Trk, &gogpx.TrkType{Name: name, TrkSeg: []*gogpx.TrkSegType{trkseg}})
+	}
+
+	return g
+}
+
+// writeGPX writes the track in a GPX file
+func writeGPX(filename string, g *gogpx.GPX) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		return err
+	}
+
+	return g.WriteIndent(f, "", "  ")
+}
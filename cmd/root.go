@@ -1,15 +1,27 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
 	"github.com/spf13/cobra"
 )
 
 var (
-	dryRun     bool
-	force      bool
-	geoservice bool
-	track      string
+	dryRun      bool
+	force       bool
+	geoservice  bool
+	track       string
+	cameraTZ    string
+	clockOffset time.Duration
+	clockSpecs  []string
+	profiles    string
+	fileNames   string
 )
 
 var rootCmd = &cobra.Command{
@@ -18,6 +30,27 @@ var rootCmd = &cobra.Command{
 	Long:    `Using a gpx track, analyze a directory with images or movies and add the GPS positions`,
 	Args:    cobra.MinimumNArgs(1),
 	Version: "1.3",
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		syncmediatrack.ExiftoolProcesses = workers
+
+		if keepMtime && updateMtime {
+			return errors.New("--keepmtime and --updatemtime can't be used together")
+		}
+
+		// the medias are not touched when the position is written in a sidecar
+		if cmd == updateMediaCmd && syncmediatrack.Sidecar && (keepMtime || updateMtime) {
+			return errors.New("--keepmtime and --updatemtime can't be used with --sidecar")
+		}
+
+		if fileNames != "" {
+			err := syncmediatrack.LoadFileNameSchemes(fileNames)
+			if err != nil {
+				return err
+			}
+		}
+
+		return loadCameraProfiles()
+	},
 }
 
 func init() {
@@ -27,8 +60,74 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&syncmediatrack.Verbose, "verbose", false, "Show more information")
 	rootCmd.PersistentFlags().StringVar(&syncmediatrack.DefaultCountry, "defaultcountry", "", "Remove this country from geocoding")
 	rootCmd.PersistentFlags().StringVar(&track, "track", "", "GPX track or a directory of GPX tracks")
+	rootCmd.PersistentFlags().StringSliceVar(&syncmediatrack.VideoUTC, "videoutc", nil, "Cameras (make or make/model) that store the date of the videos in UTC")
+	rootCmd.PersistentFlags().StringSliceVar(&syncmediatrack.VideoLocal, "videolocal", nil, "Cameras (make or make/model) that store the date of the videos in local time")
+	rootCmd.PersistentFlags().StringVar(&cameraTZ, "cameratz", "", "Time zone of the camera clock (IANA name or UTC)")
+	rootCmd.PersistentFlags().DurationVar(&clockOffset, "clockoffset", 0, "Error of the camera clock, positive if it was ahead (e.g. 3m20s or -1h)")
+	rootCmd.PersistentFlags().StringArrayVar(&clockSpecs, "clock", nil, "Camera clock profile key=value,... (name, make, model, serial, dir, tz, offset, drift, reference)")
+	rootCmd.PersistentFlags().StringVar(&profiles, "profiles", "", "JSON file with the clock profiles of the cameras")
+	rootCmd.PersistentFlags().StringVar(&fileNames, "filenames", "", "JSON file with the filename schemes of the cameras")
+	rootCmd.PersistentFlags().BoolVar(&syncmediatrack.Sidecar, "sidecar", false, "Write the GPS position in a XMP sidecar file instead of the media")
+	rootCmd.PersistentFlags().BoolVar(&syncmediatrack.SidecarExt, "sidecarext", false, "Name the new sidecar files name.ext.xmp instead of name.xmp")
+	rootCmd.PersistentFlags().StringVar(&syncmediatrack.JournalFile, "journal", "", "Journal of the changes to undo them, by default a new file in the journal directory")
 }
 
 func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
+	// stop exiftool when the user interrupts the program
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		syncmediatrack.Warning("Interrupted")
+		_ = syncmediatrack.CloseExiftool()
+		closeJournal()
+		os.Exit(130)
+	}()
+
+	err := rootCmd.Execute()
+	_ = syncmediatrack.CloseExiftool()
+	closeJournal()
+	cobra.CheckErr(err)
+
+	// the medias that could not be read or written fail the run
+	if mediaError.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// closeJournal closes the journal of the session and shows how to undo the changes
+func closeJournal() {
+	journal, err := syncmediatrack.CloseJournal()
+	if err != nil {
+		syncmediatrack.Error(err.Error())
+	}
+	if journal != "" {
+		syncmediatrack.Pass(fmt.Sprintf("The changes can be undone with: SyncMediaTrack undo %s", journal))
+	}
+}
+
+func loadCameraProfiles() error {
+	if profiles != "" {
+		err := syncmediatrack.LoadCameraProfiles(profiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, spec := range clockSpecs {
+		profile, err := syncmediatrack.ParseCameraProfile(spec)
+		if err != nil {
+			return err
+		}
+		syncmediatrack.CameraProfiles = append(syncmediatrack.CameraProfiles, profile)
+	}
+
+	if cameraTZ == "" && clockOffset == 0 {
+		return nil
+	}
+
+	return syncmediatrack.AddCameraProfile(syncmediatrack.CameraProfile{
+		TimeZone: cameraTZ,
+		Offset:   syncmediatrack.Duration(clockOffset),
+	})
 }
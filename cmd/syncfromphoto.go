@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	referencePhoto string
+	shownTime      string
+)
+
+var syncFromPhotoCmd = &cobra.Command{
+	Use:   "syncfromphoto",
+	Short: "Synchronize Media Data using a photo of the GPS clock",
+	Long:  `Using a photo of the GPS device clock and the time that it shows, correct the camera clock and add the GPS positions from the gpx track`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		if len(args) == 1 {
+			mediaDir = args[0]
+		}
+		syncFromPhotoExecute()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncFromPhotoCmd)
+	rootCmd.PersistentFlags().StringVar(&referencePhoto, "photo", "", "Photo of the GPS device clock")
+	rootCmd.PersistentFlags().StringVar(&shownTime, "shown", "", "Time shown by the GPS device in the photo (YYYY-MM-DD HH:MM:SS or HH:MM:SS)")
+}
+
+func syncFromPhotoExecute() {
+	if referencePhoto == "" || shownTime == "" {
+		syncmediatrack.Error("The reference photo and the time shown are required")
+		return
+	}
+
+	syncmediatrack.Pass("Reading reference photo...")
+
+	media, err := syncmediatrack.GetMediaDate(referencePhoto)
+	if err != nil {
+		syncmediatrack.Error(err.Error())
+		return
+	}
+
+	if media.Etime.IsZero() {
+		syncmediatrack.Error("The reference photo does not have date")
+		return
+	}
+
+	shown, err := syncmediatrack.ParseShownTime(shownTime, media.Etime)
+	if err != nil {
+		syncmediatrack.Error(err.Error())
+		return
+	}
+
+	offset := media.Etime.Sub(shown)
+	profile := calibratedProfile(media.Camera, media.Profile, offset)
+	if profile.Name == "" {
+		profile.Name = fmt.Sprintf("%s (%s)", media.Camera, referencePhoto)
+	}
+
+	fmt.Printf("[%v] - %s - %s -> %s Offset %s\n", referencePhoto, media.Camera,
+		media.Etime.Format("02/01/2006 15:04:05"), shown.Format("02/01/2006 15:04:05"), offset)
+
+	err = syncmediatrack.ReplaceCameraProfile(profile)
+	if err != nil {
+		syncmediatrack.Error(err.Error())
+		return
+	}
+
+	if saveProfile != "" && !dryRun {
+		err = syncmediatrack.SaveCameraProfile(saveProfile, profile)
+		if err != nil {
+			syncmediatrack.Warning(err.Error())
+		}
+	}
+
+	MExecute()
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo journal [files]",
+	Short: "Restore the medias changed in a session",
+	Long:  `Using the journal of a session, restore the tags and modification times of all the files changed or only the given files`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(_ *cobra.Command, args []string) {
+		undoExecute(args[0], args[1:])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
+
+func undoExecute(journalFile string, files []string) {
+	entries, err := syncmediatrack.ReadJournal(journalFile)
+	if err != nil {
+		syncmediatrack.Error(err.Error())
+		return
+	}
+
+	selected := make([]string, 0, len(files))
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			syncmediatrack.Error(err.Error())
+			return
+		}
+		selected = append(selected, path)
+	}
+
+	syncmediatrack.Pass("Restoring medias...")
+
+	restored := map[string]bool{}
+	undone := 0
+	failed := 0
+
+	// the last change of a file is undone first
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if len(selected) > 0 && !slices.Contains(selected, entry.File) {
+			continue
+		}
+
+		fmt.Printf("[%v] - %s ", entry.File, entry.Time.Format("02/01/2006 15:04:05"))
+		if entry.Created {
+			fmt.Printf("remove ")
+		} else {
+			tags := make([]string, 0, len(entry.Tags))
+			for tag := range entry.Tags {
+				tags = append(tags, tag)
+			}
+			slices.Sort(tags)
+			if len(tags) > 0 {
+				fmt.Printf("%s ", strings.Join(tags, ", "))
+			}
+			fmt.Printf("mtime %s ", entry.Mtime.Format("02/01/2006 15:04:05"))
+		}
+
+		if dryRun {
+			fmt.Println()
+			undone++
+			continue
+		}
+
+		// a file restored by a later change is not identical byte by byte to the journal
+		exact, err := syncmediatrack.UndoChange(entry, force || restored[entry.File])
+		if err != nil {
+			failed++
+			mediaError.Add(1)
+			fmt.Println(syncmediatrack.ColorRed(err))
+			continue
+		}
+		restored[entry.File] = true
+		undone++
+
+		if exact {
+			fmt.Println(syncmediatrack.ColorGreen("(restored)"))
+		} else {
+			fmt.Println(syncmediatrack.ColorYellow("(restored, the content of the file is not identical)"))
+		}
+	}
+
+	if failed == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Restored %d change(s)\n"), undone)
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Restored %d change(s), %d with error(s), use --force to restore the files modified after the session\n"), undone, failed)
+	}
+}
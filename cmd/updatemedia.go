@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
-	"github.com/karrick/godirwalk"
 	"github.com/spf13/cobra"
 )
 
@@ -22,14 +26,36 @@ type mediaGPS struct {
 var (
 	gpsOld      syncmediatrack.Trkpt
 	mediaDir    string
-	mediaValid  int
-	mediaError  int
-	mediaUpdate int
+	mediaValid  atomic.Int64
+	mediaError  atomic.Int64
+	mediaUpdate atomic.Int64
 
 	fileGPS   = map[string]mediaGPS{}
 	fileNoGPS = map[string]mediaGPS{}
+
+	inferTime     bool
+	mediaSequence []syncmediatrack.SequenceItem
+	fileDeferred  = map[string]deferredMedia{}
+
+	guessTZ        bool
+	mediaAmbiguous atomic.Int64
+	// Medias not located because the time zone or the time of the day of their date is unknown
+	mediaUnresolved atomic.Int64
+
+	maxMediaGap      time.Duration
+	maxMediaDistance float64
+
+	fillGaps    bool
+	filledTrack string
 )
 
+// deferredMedia is a media whose date is not trusted, it is located once the dates of its
+// neighbours are known
+type deferredMedia struct {
+	Time time.Time
+	GPS  syncmediatrack.Trkpt
+}
+
 var updateMediaCmd = &cobra.Command{
 	Use:   "updatemedia",
 	Short: "Synchronize Media Data from track GPX",
@@ -45,6 +71,12 @@ var updateMediaCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(updateMediaCmd)
+	rootCmd.PersistentFlags().BoolVar(&inferTime, "infertime", false, "Infer the missing or reset dates from the medias with the previous and next file number")
+	rootCmd.PersistentFlags().DurationVar(&maxMediaGap, "maxmediagap", 180*time.Second, "Maximum time between a media without position and the geotagged medias used to locate it")
+	rootCmd.PersistentFlags().Float64Var(&maxMediaDistance, "maxmediadistance", 500, "Maximum distance in meters between the geotagged medias before and after to interpolate the position")
+	rootCmd.PersistentFlags().BoolVar(&fillGaps, "fillgaps", false, "Fill the gaps of the track with the positions of the geotagged medias")
+	rootCmd.PersistentFlags().StringVar(&filledTrack, "filledtrack", "", "GPX file to write the track with the gaps filled")
+	rootCmd.PersistentFlags().BoolVar(&guessTZ, "guesstz", false, "Guess the time zone of the camera dates without time zone from the time zones of the track")
 
 	fileGPS = make(map[string]mediaGPS)
 	fileNoGPS = make(map[string]mediaGPS)
@@ -58,219 +90,380 @@ func MExecute() {
 
 	syncmediatrack.ReadTracks(track, true)
 
+	if fillGaps {
+		fillTrackGaps()
+	}
+
 	syncmediatrack.Pass("Reading medias...")
 	syncmediatrack.Pass("First pass...")
 
-	err := godirwalk.Walk(mediaDir, &godirwalk.Options{
-		Callback: func(path string, de *godirwalk.Dirent) error {
-			var location syncmediatrack.Trkpt
-			var gpsOld syncmediatrack.Trkpt
+	err := walkMedias(mediaDir, func(path string, relPath string, out io.Writer) {
+		mediaValid.Add(1)
 
-			if de.IsDir() {
-				return nil // do not remove directory that was provided top-level directory
-			}
+		fmt.Fprintf(out, "[%v] - ", relPath)
 
-			if !syncmediatrack.FileIsMedia(path) {
-				return nil
-			}
+		media, err := syncmediatrack.GetMediaDate(path)
+		if err != nil {
+			mediaError.Add(1)
+			fmt.Fprintln(out, err)
+			return
+		}
 
-			mediaValid++
+		updateMedia(out, path, media)
+	})
+	if err != nil {
+		syncmediatrack.Warning(err.Error())
+	}
 
-			relPath, err := filepath.Rel(mediaDir, path)
-			if err != nil {
-				mediaError++
-				return err
-			}
-			fmt.Printf("[%v] - ", relPath)
+	if inferTime {
+		syncmediatrack.Pass("Inferring dates...")
+		inferMediaTimes()
+	}
 
-			atime, etime, gtime, err := syncmediatrack.GetMediaDate(path, &gpsOld)
-			if err != nil {
-				mediaError++
-				fmt.Println(err)
-				return nil
-			}
+	syncmediatrack.Pass("Second pass...")
 
-			if etime.IsZero() {
-				compareDates2(atime, gtime, "A")
-			} else {
-				fmt.Printf("[A] ")
-				compareDates(atime, etime, 30)
-				compareDates2(etime, gtime, "E")
-			}
+	references := geotaggedMedias()
 
-			date := bestDate(atime, etime, gtime)
+	filenames := make([]string, 0, len(fileNoGPS))
+	for filename := range fileNoGPS {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
 
-			fmt.Printf("| ")
+	processMedias(filenames, func(filename string, out io.Writer) {
+		media := fileNoGPS[filename]
 
-			if gpsOld.Lat == 0 && gpsOld.Lon == 0 {
-				fileNoGPS[path] = mediaGPS{Time: date}
+		fmt.Fprintf(out, "[%v] - %s - No location -> ", filename, media.Time.Format("02/01/2006 15:04:05"))
 
-				fmt.Printf("No location ")
-			} else {
-				fileGPS[path] = mediaGPS{Lat: gpsOld.Lat, Lon: gpsOld.Lon, Ele: gpsOld.Ele, Time: date}
+		location, used, err := syncmediatrack.InterpolatePosition(references, media.Time, maxMediaGap, maxMediaDistance)
+		if err != nil {
+			fmt.Fprintln(out, syncmediatrack.ColorRed("("+err.Error()+")"))
+			return
+		}
 
-				fmt.Printf("Lat %v Lon %v Ele %v ", gpsOld.Lat, gpsOld.Lon, gpsOld.Ele)
+		if syncmediatrack.Verbose {
+			for _, reference := range used {
+				fmt.Fprintf(out, " Diff.sec (%.0f [%s]) ", media.Time.Sub(reference.Time).Seconds(), reference.Path)
 			}
+		}
 
-			if !syncmediatrack.GetClosesGPS(date, &location) {
-				if gpsOld.Lat != 0 && gpsOld.Lon != 0 {
-					fmt.Println()
-				} else {
-					fmt.Println(syncmediatrack.ColorRed("(There is no close time to obtain the GPS position)"))
-				}
-
-				return nil
-			}
+		fmt.Fprintf(out, "Lat %.6f Lon %.6f Ele %.0f ", location.Lat, location.Lon, location.Ele)
+		if len(used) == 2 {
+			fmt.Fprintf(out, "%s ", syncmediatrack.ColorYellow("(interpolated)"))
+		}
 
-			fmt.Printf("-> Lat %v Lon %v Ele %v ", location.Lat, location.Lon, location.Ele)
+		location.Time = syncmediatrack.FormatTrkptTime(media.Time)
 
-			if geoservice {
-				loc, _ := syncmediatrack.ReverseLocation(location)
-				if len(loc) != 0 {
-					fmt.Printf("(%s)", syncmediatrack.ColorGreen(loc))
-				}
-			}
-			if !force && gpsOld.Lat != 0 && gpsOld.Lon != 0 {
-				fmt.Println("")
-				return nil
+		if geoservice {
+			loc, _ := syncmediatrack.ReverseLocation(location)
+			if len(loc) != 0 {
+				fmt.Fprintf(out, "(%s)", syncmediatrack.ColorGreen(loc))
 			}
+		}
 
-			fmt.Println(syncmediatrack.ColorGreen("(updating)"))
+		updateMediaGPS(out, location, filename, media.Time)
+	})
 
-			mediaUpdate++
+	if mediaError.Load() == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Processed %d media(s)\n"), mediaValid.Load())
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Processed %d media(s), %d with error(s)\n"), mediaValid.Load(), mediaError.Load())
+	}
 
-			if dryRun {
-				return nil
-			}
+	if mediaAmbiguous.Load() > 0 {
+		fmt.Printf(syncmediatrack.ColorYellow("%d media(s) not located because their time zone is ambiguous, use --cameratz or a camera profile\n"), mediaAmbiguous.Load())
+	}
 
-			err = syncmediatrack.WriteGPS(location, path)
-			if err != nil {
-				fmt.Println(err)
-				return nil
-			}
+	if mediaUnresolved.Load() > 0 {
+		fmt.Printf(syncmediatrack.ColorYellow("%d media(s) not located because the time zone or the time of their date is unknown, use --cameratz or a camera profile\n"), mediaUnresolved.Load())
+	}
 
-			return nil
-		},
-		Unsorted: false,
-	})
-	if err != nil {
-		syncmediatrack.Warning(err.Error())
+	if mediaError.Load() == 0 {
+		fmt.Printf(syncmediatrack.ColorGreen("Updated %d media(s) with GPS position\n"), mediaUpdate.Load())
+	} else {
+		fmt.Printf(syncmediatrack.ColorYellow("Updated %d media(s) with GPS position, %d with error(s)\n"), mediaUpdate.Load(), mediaError.Load())
 	}
+}
 
-	syncmediatrack.Pass("Second pass...")
+// updateMedia locates the media with the instant of its best date, the dates without time zone are
+// read in the time zone of the camera or guessed from the track with --guesstz
+func updateMedia(out io.Writer, path string, media syncmediatrack.MediaDate) {
+	atime, etime, gtime, ftime := media.Atime, media.Etime, media.Gtime, media.Ftime
+	gpsOld := media.GPS
 
-	for filename, media := range fileNoGPS {
-		var location mediaGPS
-		var tlocation syncmediatrack.Trkpt
+	if media.Profile != nil {
+		fmt.Fprintf(out, "{%s} ", syncmediatrack.ColorBlue(media.Profile))
+	}
 
-		fmt.Printf("[%v] - %s - No location -> ", filename, media.Time.Format("02/01/2006 15:04:05"))
+	switch {
+	case !etime.IsZero():
+		fmt.Fprintf(out, "[A] ")
+		compareDates(out, atime, etime, 30)
+		compareDates2(out, etime, gtime, "E")
+	case !ftime.IsZero():
+		fmt.Fprintf(out, "[A] ")
+		compareDates(out, atime, ftime, 30)
+		compareDates2(out, ftime, gtime, "F")
+	default:
+		compareDates2(out, atime, gtime, "A")
+	}
 
-		if !getClosesMedia(media, &location) {
-			fmt.Println(syncmediatrack.ColorRed("(There is no close time to obtain the GPS position)"))
-			continue
+	date, source, resolved := media.BestInstant()
+	fmt.Fprintf(out, "(%s) ", source)
+
+	if guessTZ && !resolved && source == syncmediatrack.DateSourceEXIF {
+		guess := syncmediatrack.GuessTimeZone(etime)
+		switch {
+		case guess.Found():
+			date, resolved = guess.Matches[0].Time, true
+			fmt.Fprintf(out, "{%s} ", syncmediatrack.ColorBlue(guess.Matches[0].Zone))
+		case guess.Ambiguous():
+			// do not choose, the media would be located in the wrong place
+			mediaAmbiguous.Add(1)
+			fmt.Fprintln(out, syncmediatrack.ColorYellow("| (ambiguous time zone: "+describeZoneGuess(guess)+")"))
+			return
+		default:
+			fmt.Fprintf(out, "%s ", syncmediatrack.ColorYellow("(no time zone matches the track)"))
 		}
+	}
 
-		fmt.Printf("Lat %v Lon %v Ele %v ", media.Lat, media.Lon, media.Ele)
-
-		tlocation.Lat = location.Lat
-		tlocation.Lon = location.Lon
-		tlocation.Ele = location.Ele
-		tlocation.Time = location.Time.Format("2006-01-02T15:04:05Z")
+	if inferTime {
+		// the date of the camera is missing or reset, wait to know the dates of the neighbours
+		suspect := gtime.IsZero() && syncmediatrack.DateIsReset(etime)
 
-		if geoservice {
-			loc, _ := syncmediatrack.ReverseLocation(tlocation)
-			if len(loc) != 0 {
-				fmt.Printf("(%s)", syncmediatrack.ColorGreen(loc))
+		item, ok := syncmediatrack.NewSequenceItem(path, date)
+		if ok && (resolved || suspect) {
+			mediaMutex.Lock()
+			if suspect {
+				item.Time = etime
+				fileDeferred[path] = deferredMedia{Time: date, GPS: gpsOld}
 			}
+			mediaSequence = append(mediaSequence, item)
+			mediaMutex.Unlock()
 		}
-		if !force && gpsOld.Lat != 0 && gpsOld.Lon != 0 {
-			fmt.Println("")
-			continue
+
+		if ok && suspect {
+			fmt.Fprintln(out, syncmediatrack.ColorYellow("| (date not trusted, deferred)"))
+			return
+		}
+	}
+
+	if !resolved {
+		// matched as UTC the media would be located in the wrong place
+		mediaUnresolved.Add(1)
+		if source == syncmediatrack.DateSourceFilename && media.FtimeDateOnly {
+			fmt.Fprintln(out, syncmediatrack.ColorYellow("| (only the day of the date is known)"))
+		} else {
+			fmt.Fprintln(out, syncmediatrack.ColorYellow("| (unknown time zone, use --cameratz or a camera profile)"))
 		}
+		return
+	}
 
-		fmt.Println(syncmediatrack.ColorGreen("(updating)"))
+	locateMedia(out, path, date, gpsOld)
+}
 
-		mediaUpdate++
+// fillTrackGaps adds the positions of the geotagged medias where the track has no points
+func fillTrackGaps() {
+	syncmediatrack.Pass("Filling track gaps...")
 
-		if dryRun {
-			continue
-		}
+	medias := readGeotaggedMedias(mediaDir)
+	syncmediatrack.SortGeoMedias(medias)
 
-		err = syncmediatrack.WriteGPS(tlocation, filename)
-		if err != nil {
-			fmt.Println(err)
-		}
+	added := syncmediatrack.FillTrackGaps(medias, syncmediatrack.MaxTime*time.Second)
+	for _, media := range added {
+		fmt.Printf("[%v] - %s Lat %v Lon %v Ele %v %s\n", media.Path, media.Time.Format("02/01/2006 15:04:05"),
+			media.GPS.Lat, media.GPS.Lon, media.GPS.Ele, syncmediatrack.ColorGreen("(added to the track)"))
 	}
+	fmt.Printf("Added %d position(s) to the track\n", len(added))
 
-	if mediaError == 0 {
-		fmt.Printf(syncmediatrack.ColorGreen("Processed %d media(s)\n"), mediaValid)
-	} else {
-		fmt.Printf(syncmediatrack.ColorYellow("Processed %d media(s), %d with error(s)\n"), mediaValid, mediaError)
+	if filledTrack == "" || dryRun {
+		return
 	}
 
-	if mediaError == 0 {
-		fmt.Printf(syncmediatrack.ColorGreen("Updated %d media(s) with GPS position\n"), mediaUpdate)
-	} else {
-		fmt.Println(syncmediatrack.ColorYellow("No media file has been updated with the GPS position"))
+	if _, err := os.Stat(filledTrack); err == nil && !force {
+		syncmediatrack.Error(fmt.Sprintf("%s already exists, use --force to overwrite it", filledTrack))
+		return
+	}
+
+	if err := writeGPX(filledTrack, tracksToGPX(syncmediatrack.DataGPX)); err != nil {
+		syncmediatrack.Error(err.Error())
 	}
 }
 
-func getClosesMedia(media mediaGPS, closestPoint *mediaGPS) bool {
-	var closestDuration time.Duration
-	var closestFilename string
+// describeZoneGuess lists the instants that agree with the track
+func describeZoneGuess(guess syncmediatrack.ZoneGuess) string {
+	matches := make([]string, 0, len(guess.Matches))
+	for _, match := range guess.Matches {
+		matches = append(matches, fmt.Sprintf("%s %s", match.Zone, match.Time.Format("02/01/2006 15:04:05 MST")))
+	}
 
-	for filename, gps := range fileGPS {
-		duration := media.Time.Sub(gps.Time)
-		if duration < 0 {
-			duration = -duration
+	return strings.Join(matches, ", ")
+}
+
+// locateMedia shows the position of the media and updates it with the position of the track
+func locateMedia(out io.Writer, path string, date time.Time, gpsOld syncmediatrack.Trkpt) {
+	var location syncmediatrack.Trkpt
+
+	fmt.Fprintf(out, "| ")
+
+	if gpsOld.Lat == 0 && gpsOld.Lon == 0 {
+		fmt.Fprintf(out, "No location ")
+	} else {
+		mediaMutex.Lock()
+		fileGPS[path] = mediaGPS{Lat: gpsOld.Lat, Lon: gpsOld.Lon, Ele: gpsOld.Ele, Time: date}
+		mediaMutex.Unlock()
+
+		fmt.Fprintf(out, "Lat %v Lon %v Ele %v ", gpsOld.Lat, gpsOld.Lon, gpsOld.Ele)
+	}
+
+	if !syncmediatrack.GetClosesGPS(date, &location) {
+		if gpsOld.Lat != 0 && gpsOld.Lon != 0 {
+			fmt.Fprintln(out)
+		} else {
+			// try later with the medias that have GPS position
+			mediaMutex.Lock()
+			fileNoGPS[path] = mediaGPS{Time: date}
+			mediaMutex.Unlock()
+			fmt.Fprintln(out, syncmediatrack.ColorRed("(There is no close time to obtain the GPS position)"))
 		}
 
-		if closestDuration == 0 || duration < closestDuration {
-			*closestPoint = gps
-			closestDuration = duration
-			closestFilename = filename
+		return
+	}
+
+	fmt.Fprintf(out, "-> Lat %v Lon %v Ele %v ", location.Lat, location.Lon, location.Ele)
+
+	if geoservice {
+		loc, _ := syncmediatrack.ReverseLocation(location)
+		if len(loc) != 0 {
+			fmt.Fprintf(out, "(%s)", syncmediatrack.ColorGreen(loc))
 		}
 	}
+	if !force && gpsOld.Lat != 0 && gpsOld.Lon != 0 {
+		fmt.Fprintln(out)
+		return
+	}
 
-	if syncmediatrack.Verbose && closestDuration.Seconds() < 3600 {
-		fmt.Printf(" Diff.sec (%.0f [%s]) ", closestDuration.Seconds(), closestFilename)
+	updateMediaGPS(out, location, path, date)
+}
+
+// writeMediaGPS writes the position in the media, its modification time is kept or set to the
+// instant of the media, the media is not touched when the position is written in a sidecar
+func writeMediaGPS(location syncmediatrack.Trkpt, filename string, date time.Time) error {
+	if syncmediatrack.Sidecar {
+		return syncmediatrack.WriteGPS(location, filename)
 	}
 
-	if closestDuration.Seconds() > 180 || len(closestFilename) == 0 {
-		return false
+	return writeWithMtime(filename, date, func() error {
+		return syncmediatrack.WriteGPS(location, filename)
+	})
+}
+
+// updatingLabel shows that the media and its companion files are updated
+func updatingLabel(path string) string {
+	if len(companions[path]) == 0 {
+		return "(updating)"
 	}
 
-	return true
+	return fmt.Sprintf("(updating with %d companion file(s))", len(companions[path]))
 }
 
-func bestDate(atime time.Time, etime time.Time, gtime time.Time) time.Time {
-	if !gtime.IsZero() {
-		return gtime
+// updateMediaGPS writes the position in the media and its companion files, the media is counted
+// as updated only when the position of all of them has been written and checked
+func updateMediaGPS(out io.Writer, location syncmediatrack.Trkpt, path string, date time.Time) {
+	if !dryRun {
+		var errs []error
+		for _, filename := range append([]string{path}, companions[path]...) {
+			err := writeMediaGPS(location, filename, date)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			mediaError.Add(1)
+			fmt.Fprintln(out, syncmediatrack.ColorRed("(not updated)"))
+			for _, err := range errs {
+				fmt.Fprintln(out, syncmediatrack.ColorRed(err))
+			}
+			return
+		}
 	}
-	if !etime.IsZero() {
-		return etime
+
+	mediaUpdate.Add(1)
+
+	fmt.Fprintln(out, syncmediatrack.ColorGreen(updatingLabel(path)))
+}
+
+// inferMediaTimes estimates the date of the deferred medias from their neighbours and locates them
+func inferMediaTimes() {
+	// the medias are read at the same time, the order of the paths breaks the ties of the numbers
+	sort.Slice(mediaSequence, func(i, j int) bool {
+		return mediaSequence[i].Path < mediaSequence[j].Path
+	})
+	syncmediatrack.InferSequenceTimes(mediaSequence)
+
+	for _, item := range mediaSequence {
+		relPath, err := filepath.Rel(mediaDir, item.Path)
+		if err != nil {
+			relPath = item.Path
+		}
+
+		media, deferred := fileDeferred[item.Path]
+		if !deferred {
+			if item.OutOfSequence {
+				fmt.Printf("[%v] - %s %s\n", relPath, item.Time.Format("02/01/2006 15:04:05"), syncmediatrack.ColorYellow("(out of sequence)"))
+			}
+			continue
+		}
+
+		fmt.Printf("[%v] - %s ", relPath, media.Time.Format("02/01/2006 15:04:05"))
+
+		date := media.Time
+		if item.Inferred {
+			date = item.InferredTime
+			fmt.Printf("-> %s %s ", date.Format("02/01/2006 15:04:05"), syncmediatrack.ColorYellow("(inferred)"))
+		} else {
+			fmt.Printf("%s ", syncmediatrack.ColorRed("(the date can't be inferred)"))
+		}
+
+		locateMedia(os.Stdout, item.Path, date, media.GPS)
 	}
+}
+
+// geotaggedMedias returns the medias with GPS position sorted by time, their dates are instants
+// because only the medias whose time zone is known are located
+func geotaggedMedias() []syncmediatrack.GeoMedia {
+	medias := make([]syncmediatrack.GeoMedia, 0, len(fileGPS))
+	for filename, gps := range fileGPS {
+		medias = append(medias, syncmediatrack.GeoMedia{
+			Path: filename,
+			Time: gps.Time,
+			GPS:  syncmediatrack.Trkpt{Lat: gps.Lat, Lon: gps.Lon, Ele: gps.Ele},
+		})
+	}
+
+	syncmediatrack.SortGeoMedias(medias)
 
-	return atime
+	return medias
 }
 
-func compareDates(t1 time.Time, t2 time.Time, sec float64) {
+func compareDates(out io.Writer, t1 time.Time, t2 time.Time, sec float64) {
 	diff := math.Abs(t1.Sub(t2).Seconds())
 
 	if diff > sec {
-		fmt.Printf("%s -> ", syncmediatrack.ColorYellow(t1.Format("02/01/2006 15:04:05")))
+		fmt.Fprintf(out, "%s -> ", syncmediatrack.ColorYellow(t1.Format("02/01/2006 15:04:05")))
 	} else {
-		fmt.Printf("%s -> ", t1.Format("02/01/2006 15:04:05"))
+		fmt.Fprintf(out, "%s -> ", t1.Format("02/01/2006 15:04:05"))
 	}
 }
 
-func compareDates2(old time.Time, gtime time.Time, prefix string) {
-	fmt.Printf("[%s] ", prefix)
+func compareDates2(out io.Writer, old time.Time, gtime time.Time, prefix string) {
+	fmt.Fprintf(out, "[%s] ", prefix)
 	if gtime.IsZero() {
-		fmt.Printf("%s ", old.Format("02/01/2006 15:04:05"))
+		fmt.Fprintf(out, "%s ", old.Format("02/01/2006 15:04:05"))
 	} else {
-		compareDates(old, gtime, 80)
-		fmt.Printf("[G] %s ", gtime.Format("02/01/2006 15:04:05"))
+		compareDates(out, old, gtime, 80)
+		fmt.Fprintf(out, "[G] %s ", gtime.Format("02/01/2006 15:04:05"))
 	}
 }
 
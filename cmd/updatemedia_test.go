@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+)
+
+func TestUpdateMediaNaiveDate(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	defer func(data map[string]syncmediatrack.Gpx) {
+		syncmediatrack.DataGPX = data
+		dryRun, guessTZ = false, false
+		fileGPS, fileNoGPS = map[string]mediaGPS{}, map[string]mediaGPS{}
+		mediaUpdate.Store(0)
+		mediaUnresolved.Store(0)
+	}(syncmediatrack.DataGPX)
+
+	// a track in Madrid (UTC+1) from 10:00 to 12:00 UTC, a point every minute with its own latitude
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	var gpx syncmediatrack.Gpx
+	for i := 0; i <= 120; i++ {
+		gpx.Trk.Trkseg.Trkpt = append(gpx.Trk.Trkseg.Trkpt, syncmediatrack.Trkpt{
+			Lat:  40.4 + float64(i)/1024,
+			Lon:  -3.7,
+			Time: syncmediatrack.FormatTrkptTime(start.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	syncmediatrack.DataGPX = map[string]syncmediatrack.Gpx{"track.gpx": gpx}
+	dryRun = true
+
+	// the camera shows 11:50 in Madrid, 10:50 UTC
+	naive := time.Date(2024, 1, 28, 11, 50, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		zone     *time.Location
+		guess    bool
+		expected string
+	}{
+		{"time zone of the camera", madrid, false, "-> Lat 40.448828125 "},
+		{"time zone guessed from the track", nil, true, "-> Lat 40.448828125 "},
+		{"unknown time zone", nil, false, "(unknown time zone, use --cameratz or a camera profile)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaUpdate.Store(0)
+			mediaUnresolved.Store(0)
+			guessTZ = tt.guess
+
+			media := syncmediatrack.MediaDate{Atime: naive, Etime: naive, Naive: true, Zone: tt.zone}
+
+			var out bytes.Buffer
+			updateMedia(&out, "IMG_0001.JPG", media)
+
+			if !strings.Contains(out.String(), tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, out.String())
+			}
+
+			located := tt.zone != nil || tt.guess
+			if located && (mediaUpdate.Load() != 1 || mediaUnresolved.Load() != 0) {
+				t.Errorf("Expected the media located, got %d updated %d unresolved", mediaUpdate.Load(), mediaUnresolved.Load())
+			}
+			if !located && (mediaUpdate.Load() != 0 || mediaUnresolved.Load() != 1) {
+				t.Errorf("Expected the media not located, got %d updated %d unresolved", mediaUpdate.Load(), mediaUnresolved.Load())
+			}
+		})
+	}
+}
+
+func TestUpdateMediaSidecarMtime(t *testing.T) {
+	defer func() {
+		syncmediatrack.Sidecar = false
+		keepMtime, updateMtime = false, false
+	}()
+
+	tests := []struct {
+		name        string
+		sidecar     bool
+		keepMtime   bool
+		updateMtime bool
+		err         bool
+	}{
+		{"sidecar", true, false, false, false},
+		{"keepmtime", false, true, false, false},
+		{"sidecar and keepmtime", true, true, false, true},
+		{"sidecar and updatemtime", true, false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			syncmediatrack.Sidecar = tt.sidecar
+			keepMtime, updateMtime = tt.keepMtime, tt.updateMtime
+
+			err := rootCmd.PersistentPreRunE(updateMediaCmd, nil)
+			if (err != nil) != tt.err {
+				t.Errorf("Unexpected error %v", err)
+			}
+		})
+	}
+}
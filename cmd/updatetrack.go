@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -41,7 +40,8 @@ func updateTrackExecute() {
 		fmt.Printf("[%v] -> ", basename)
 
 		trkpt := GetPosFromGPX(gpx)
-		trkptTime := syncmediatrack.GetTimeFromTrkpt(trkpt)
+		// the name has the local time of the start of the track
+		trkptTime := syncmediatrack.TrkptLocalTime(trkpt)
 		if trkptTime.IsZero() {
 			syncmediatrack.Warning("No time found in GPX file")
 			continue
@@ -137,21 +137,7 @@ func updateHeaderName(filename string, basename string) {
 		// remove extension from basename
 		g.Metadata.Name = basename[:len(basename)-len(filepath.Ext(basename))]
 
-		f, err = os.Create(filename)
-		if err != nil {
-			fmt.Println(syncmediatrack.ColorRed(err))
-			return
-		}
-		defer f.Close()
-
-		// write xml header
-		_, err = f.WriteString(xml.Header)
-		if err != nil {
-			fmt.Println(syncmediatrack.ColorRed(err))
-			return
-		}
-
-		if err := g.WriteIndent(f, "", "  "); err != nil {
+		if err := writeGPX(filename, g); err != nil {
 			fmt.Println(syncmediatrack.ColorRed(err))
 		}
 	}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	syncmediatrack "github.com/inode64/SyncMediaTrack/lib"
+	"github.com/karrick/godirwalk"
+)
+
+var (
+	// Number of medias read or written at the same time
+	workers int
+
+	// mediaMutex protects the results shared by the medias processed at the same time
+	mediaMutex sync.Mutex
+
+	// Companion files of the medias, they get the same position and date as the media
+	companions = map[string][]string{}
+)
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&workers, "workers", 4, "Number of medias read or written at the same time")
+}
+
+// walkMedias calls fn with each media of the directory, the companion files of the media are not
+// passed to fn but they are recorded in companions. The metadata of the medias is read by exiftool
+// in batches of syncmediatrack.MetadataBatch files
+func walkMedias(dir string, fn func(path string, relPath string, out io.Writer)) error {
+	var all, files []string
+
+	err := godirwalk.Walk(dir, &godirwalk.Options{
+		Callback: func(path string, de *godirwalk.Dirent) error {
+			if de.IsDir() {
+				return nil // do not remove directory that was provided top-level directory
+			}
+
+			kind, err := syncmediatrack.ClassifyFile(path)
+			if err != nil {
+				mediaError.Add(1)
+				syncmediatrack.Warning(fmt.Sprintf("%s can't be read, error: %s", path, err))
+				return nil
+			}
+
+			if !kind.IsMedia() {
+				return nil
+			}
+
+			all = append(all, path)
+
+			return nil
+		},
+		Unsorted: false,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, group := range syncmediatrack.GroupCompanions(all) {
+		files = append(files, group.Path)
+		if len(group.Companions) > 0 {
+			companions[group.Path] = group.Companions
+		}
+	}
+
+	for start := 0; start < len(files); start += syncmediatrack.MetadataBatch {
+		batch := files[start:min(start+syncmediatrack.MetadataBatch, len(files))]
+
+		if err := syncmediatrack.PrefetchMetadata(batch); err != nil {
+			return err
+		}
+
+		processMedias(batch, func(path string, out io.Writer) {
+			relPath, err := filepath.Rel(dir, path)
+			if err != nil {
+				relPath = path
+			}
+
+			fn(path, relPath, out)
+		})
+	}
+
+	return nil
+}
+
+// processMedias calls fn with each media using the workers, the output written by fn is shown in
+// the order of the medias
+func processMedias(paths []string, fn func(path string, out io.Writer)) {
+	outputs := make([]bytes.Buffer, len(paths))
+	done := make([]chan struct{}, len(paths))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	go func() {
+		for i := range paths {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for w := 0; w < max(workers, 1); w++ {
+		go func() {
+			for i := range jobs {
+				fn(paths[i], &outputs[i])
+				close(done[i])
+			}
+		}()
+	}
+
+	for i := range paths {
+		<-done[i]
+		_, _ = outputs[i].WriteTo(os.Stdout)
+	}
+}
@@ -0,0 +1,112 @@
+package syncmediatrack
+
+import (
+	"time"
+)
+
+// Typical causes of the difference between the dates of a media
+const (
+	// DatePatternTimeZone is a difference of whole hours, the camera is in another time zone
+	DatePatternTimeZone = "timezone"
+	// DatePatternDST is a difference of one hour, the camera has not changed to summer or winter time
+	DatePatternDST = "dst"
+	// DatePatternReset is a date of 1970 or 2000, the camera clock has been reset
+	DatePatternReset = "reset"
+)
+
+// Largest difference between time zones
+const maxTimeZoneDiff = 14 * time.Hour
+
+// DateIssue is a date of a media that disagrees with its most reliable date
+type DateIssue struct {
+	Source  string    `json:"source"`
+	Date    time.Time `json:"date"`
+	Diff    Duration  `json:"diff"`
+	Seconds float64   `json:"seconds"`
+	Pattern string    `json:"pattern,omitempty"`
+}
+
+// AuditMediaDate compares the dates of the media with its most reliable date, the dates of the
+// camera and the filename must agree within maxDiff and the modification time within maxMtimeDiff.
+// The dates without time zone are read in the time zone of the camera, when it is unknown they are
+// only compared with the other dates without time zone
+func AuditMediaDate(media MediaDate, maxDiff time.Duration, maxMtimeDiff time.Duration) []DateIssue {
+	reference, source, absolute := media.BestInstant()
+
+	// the day of the filename is compared with the day shown by the camera
+	referenceDay := reference
+	if absolute && media.Zone != nil {
+		referenceDay = wallTime(reference.In(media.Zone), time.UTC)
+	}
+
+	dates := []struct {
+		source string
+		date   time.Time
+		limit  time.Duration
+		naive  bool
+	}{
+		{DateSourceEXIF, media.Etime, maxDiff, media.Naive},
+		{DateSourceFilename, media.Ftime, maxDiff, media.ftimeNaive()},
+		{DateSourceMtime, media.Atime, maxMtimeDiff, false},
+	}
+
+	var issues []DateIssue
+	for _, d := range dates {
+		if d.source == source || d.date.IsZero() {
+			continue
+		}
+
+		date := d.date
+		var diff time.Duration
+		if d.source == DateSourceFilename && media.FtimeDateOnly {
+			// only the day is known
+			if date.Format("20060102") == referenceDay.Format("20060102") {
+				continue
+			}
+			diff = date.Sub(referenceDay)
+		} else {
+			instant := !d.naive || media.Zone != nil
+			if d.naive && instant {
+				date = wallTime(date, media.Zone)
+			}
+
+			// a wall clock in an unknown time zone can't be compared with an instant
+			if instant != absolute {
+				continue
+			}
+
+			diff = date.Sub(reference)
+			if diff.Abs() <= d.limit {
+				continue
+			}
+		}
+
+		issues = append(issues, DateIssue{
+			Source:  d.source,
+			Date:    date,
+			Diff:    Duration(diff),
+			Seconds: diff.Seconds(),
+			Pattern: datePattern(date, diff, d.limit),
+		})
+	}
+
+	return issues
+}
+
+// datePattern returns the typical cause of the difference, empty if it is not known
+func datePattern(date time.Time, diff time.Duration, tolerance time.Duration) string {
+	if date.Year() < ResetYear {
+		return DatePatternReset
+	}
+
+	hours := diff.Round(time.Hour)
+	if hours == 0 || hours.Abs() > maxTimeZoneDiff || (diff-hours).Abs() > tolerance {
+		return ""
+	}
+
+	if hours.Abs() == time.Hour {
+		return DatePatternDST
+	}
+
+	return DatePatternTimeZone
+}
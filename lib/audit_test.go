@@ -0,0 +1,87 @@
+package syncmediatrack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuditMediaDate(t *testing.T) {
+	gtime := time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)
+
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	// the camera shows the local time of Madrid (UTC+2) without time zone
+	naive := time.Date(2023, 3, 26, 11, 59, 32, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		media    MediaDate
+		expected []DateIssue
+	}{
+		{
+			name:  "agree",
+			media: MediaDate{Gtime: gtime, Etime: gtime.Add(20 * time.Second), Atime: gtime.Add(10 * time.Second)},
+		},
+		{
+			name:     "time zone",
+			media:    MediaDate{Gtime: gtime, Etime: gtime.Add(-5*time.Hour + 30*time.Second), Atime: gtime},
+			expected: []DateIssue{{Source: DateSourceEXIF, Pattern: DatePatternTimeZone}},
+		},
+		{
+			name:     "daylight saving time",
+			media:    MediaDate{Etime: gtime.Add(time.Hour), Ftime: gtime, Zone: time.UTC, Atime: gtime.Add(time.Hour)},
+			expected: []DateIssue{{Source: DateSourceFilename, Pattern: DatePatternDST}},
+		},
+		{
+			name:  "reset",
+			media: MediaDate{Gtime: gtime, Etime: time.Date(2000, 1, 1, 0, 12, 0, 0, time.UTC), Atime: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expected: []DateIssue{
+				{Source: DateSourceEXIF, Pattern: DatePatternReset},
+				{Source: DateSourceMtime, Pattern: DatePatternReset},
+			},
+		},
+		{
+			name:     "unknown",
+			media:    MediaDate{Etime: gtime, Atime: gtime.Add(3 * 24 * time.Hour)},
+			expected: []DateIssue{{Source: DateSourceMtime}},
+		},
+		{
+			name:  "without time zone",
+			media: MediaDate{Gtime: gtime, Etime: naive, Naive: true, Zone: madrid, Atime: gtime.Add(10 * time.Second)},
+		},
+		{
+			name:     "without time zone in another time zone",
+			media:    MediaDate{Gtime: gtime, Etime: naive.Add(-3 * time.Hour), Naive: true, Zone: madrid, Atime: gtime},
+			expected: []DateIssue{{Source: DateSourceEXIF, Pattern: DatePatternTimeZone}},
+		},
+		{
+			name:  "without time zone and unknown time zone",
+			media: MediaDate{Etime: naive, Naive: true, Atime: gtime.Add(10 * time.Second)},
+		},
+		{
+			name:  "filename with only the day in the time zone of the camera",
+			media: MediaDate{Gtime: time.Date(2023, 3, 26, 23, 30, 0, 0, time.UTC), Ftime: time.Date(2023, 3, 27, 0, 0, 0, 0, time.UTC), FtimeDateOnly: true, Zone: madrid, Atime: time.Date(2023, 3, 26, 23, 30, 0, 0, time.UTC)},
+		},
+		{
+			name:  "filename with only the day",
+			media: MediaDate{Etime: gtime, Ftime: time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC), FtimeDateOnly: true, Atime: gtime},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := AuditMediaDate(tt.media, 80*time.Second, 30*time.Second)
+			if len(issues) != len(tt.expected) {
+				t.Fatalf("Expected %d issues, got %+v", len(tt.expected), issues)
+			}
+
+			for i, issue := range issues {
+				if issue.Source != tt.expected[i].Source || issue.Pattern != tt.expected[i].Pattern {
+					t.Errorf("Expected %s %s, got %s %s", tt.expected[i].Source, tt.expected[i].Pattern, issue.Source, issue.Pattern)
+				}
+			}
+		})
+	}
+}
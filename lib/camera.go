@@ -0,0 +1,74 @@
+package syncmediatrack
+
+import (
+	"strings"
+	"time"
+)
+
+// Camera identifies the device that recorded a media
+type Camera struct {
+	Make   string
+	Model  string
+	Serial string
+}
+
+var (
+	VideoUTC   []string
+	VideoLocal []string
+)
+
+// Phones follow the QuickTime specification and store the dates of the videos in UTC,
+// most of the cameras store the local time
+var defaultVideoUTC = []string{"Apple", "Google", "samsung", "Xiaomi", "HUAWEI", "HONOR", "OnePlus", "OPPO", "realme", "vivo", "motorola"}
+
+func (c Camera) String() string {
+	return strings.Join(strings.Fields(strings.Join([]string{c.Make, c.Model, c.Serial}, " ")), " ")
+}
+
+// VideoIsUTC checks if the QuickTime dates of the videos recorded by the camera are stored in UTC.
+// The rules are given as "make" or "make/model", the most specific rule wins and the user rules
+// take precedence over the default ones
+func VideoIsUTC(cameraMake string, cameraModel string) bool {
+	utc := matchCameraRules(VideoUTC, cameraMake, cameraModel)
+	local := matchCameraRules(VideoLocal, cameraMake, cameraModel)
+
+	if utc > 0 || local > 0 {
+		return utc > local
+	}
+
+	return matchCameraRules(defaultVideoUTC, cameraMake, cameraModel) > 0
+}
+
+// matchCameraRules returns 2 if a rule matches make and model, 1 if a rule matches only the make
+// and 0 if no rule matches
+func matchCameraRules(rules []string, cameraMake string, cameraModel string) int {
+	match := 0
+
+	for _, rule := range rules {
+		ruleMake, ruleModel, hasModel := strings.Cut(rule, "/")
+		if !strings.EqualFold(strings.TrimSpace(ruleMake), strings.TrimSpace(cameraMake)) {
+			continue
+		}
+
+		if !hasModel {
+			match = max(match, 1)
+			continue
+		}
+
+		if strings.EqualFold(strings.TrimSpace(ruleModel), strings.TrimSpace(cameraModel)) {
+			return 2
+		}
+	}
+
+	return match
+}
+
+// videoLocalTime converts a video date stored in UTC to the local time of the position where
+// it was recorded, or to the local time of the system if the position is unknown
+func videoLocalTime(t time.Time, gps *Trkpt) time.Time {
+	if gps.Lat != 0 || gps.Lon != 0 {
+		return UpdateGPSDateTime(t, gps.Lat, gps.Lon)
+	}
+
+	return t.In(time.Local)
+}
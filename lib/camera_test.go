@@ -0,0 +1,74 @@
+package syncmediatrack
+
+import (
+	"testing"
+)
+
+func TestMatchCameraRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		make     string
+		model    string
+		expected int
+	}{
+		{"no rules", nil, "Canon", "Canon EOS R5", 0},
+		{"make", []string{"Canon"}, "Canon", "Canon EOS R5", 1},
+		{"make and model", []string{"Canon/Canon EOS R5"}, "Canon", "Canon EOS R5", 2},
+		{"make and model wins over make", []string{"Canon", "Canon/Canon EOS R5"}, "Canon", "Canon EOS R5", 2},
+		{"other model", []string{"Canon/Canon EOS R6"}, "Canon", "Canon EOS R5", 0},
+		{"other model and make", []string{"Canon/Canon EOS R6", "Canon"}, "Canon", "Canon EOS R5", 1},
+		{"case", []string{"CANON/canon eos r5"}, "Canon", "Canon EOS R5", 2},
+		{"whitespace", []string{" Canon / Canon EOS R5 "}, "Canon ", " Canon EOS R5", 2},
+		{"unknown camera", []string{"Canon", "Apple/iPhone 15"}, "Acme", "X100", 0},
+		{"empty make", []string{"Canon"}, "", "", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := matchCameraRules(test.rules, test.make, test.model)
+			if result != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestVideoIsUTC(t *testing.T) {
+	defer func(utc, local []string) {
+		VideoUTC, VideoLocal = utc, local
+	}(VideoUTC, VideoLocal)
+
+	tests := []struct {
+		name     string
+		utc      []string
+		local    []string
+		make     string
+		model    string
+		expected bool
+	}{
+		{"default phone", nil, nil, "Apple", "iPhone 15", true},
+		{"default phone case", nil, nil, "SAMSUNG", "SM-G991B", true},
+		{"default camera", nil, nil, "Canon", "Canon EOS R5", false},
+		{"unknown camera", nil, nil, "", "", false},
+		{"user make over default", nil, []string{"Apple"}, "Apple", "iPhone 15", false},
+		{"user rule for a camera", []string{"Canon"}, nil, "Canon", "Canon EOS R5", true},
+		{"model over make", []string{"Canon"}, []string{"Canon/Canon EOS R5"}, "Canon", "Canon EOS R5", false},
+		{"make over other model", []string{"Canon"}, []string{"Canon/Canon EOS R6"}, "Canon", "Canon EOS R5", true},
+		{"model over user make", []string{"Apple/iPhone 15"}, []string{"Apple"}, "Apple", "iPhone 15", true},
+		{"same precedence is local", []string{"Sony"}, []string{"Sony"}, "Sony", "ILCE-7M3", false},
+		{"user rules of other camera", []string{"Canon"}, []string{"Sony"}, "Apple", "iPhone 15", true},
+		{"case and whitespace", []string{" gopro / hero12 black "}, nil, "GoPro", "HERO12 Black", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			VideoUTC, VideoLocal = test.utc, test.local
+
+			result := VideoIsUTC(test.make, test.model)
+			if result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
@@ -2,10 +2,15 @@ package syncmediatrack
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/fatih/color"
 )
 
+// Messages is the writer of the errors and warnings, the standard error when the output is JSON
+var Messages io.Writer = os.Stdout
+
 var (
 	ColorBlue   = color.New(color.FgBlue).SprintFunc()
 	ColorGreen  = color.New(color.FgGreen).SprintFunc()
@@ -14,11 +19,11 @@ var (
 )
 
 func Error(s string) {
-	fmt.Println(Colorize(s, color.FgRed))
+	fmt.Fprintln(Messages, Colorize(s, color.FgRed))
 }
 
 func Warning(s string) {
-	fmt.Println(Colorize(s, color.FgRed))
+	fmt.Fprintln(Messages, Colorize(s, color.FgRed))
 }
 
 func Notice(s string) {
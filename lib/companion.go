@@ -0,0 +1,64 @@
+package syncmediatrack
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Files written by the camera next to a media, as the low resolution video and the thumbnail of GoPro
+var companionExtensions = []string{".lrv", ".thm"}
+
+// MediaGroup is a media and the files recorded with it, as the JPEG of a RAW, the video of a Live
+// Photo or the low resolution video of a GoPro, they share the same position
+type MediaGroup struct {
+	Path       string
+	Companions []string
+}
+
+// GroupCompanions groups the medias of the same directory with the same base name, or with the
+// same recording ID and chapter for GoPro. The media of each group is the first by path that is
+// not a companion file
+func GroupCompanions(paths []string) []MediaGroup {
+	members := map[string][]string{}
+	for _, path := range paths {
+		key := companionKey(path)
+		members[key] = append(members[key], path)
+	}
+
+	groups := make([]MediaGroup, 0, len(members))
+	for _, files := range members {
+		sort.Slice(files, func(i, j int) bool {
+			ci, cj := isCompanionFile(files[i]), isCompanionFile(files[j])
+			if ci != cj {
+				return !ci
+			}
+			return files[i] < files[j]
+		})
+
+		groups = append(groups, MediaGroup{Path: files[0], Companions: files[1:]})
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Path < groups[j].Path
+	})
+
+	return groups
+}
+
+func companionKey(path string) string {
+	dir, base := filepath.Split(path)
+
+	// GoPro names the files of a recording with different prefixes: GH010123.MP4 and GL010123.LRV
+	if name, ok := ParseFileName(base); ok && name.Scheme == "GoPro" {
+		return fmt.Sprintf("%s\x00GoPro %s %d", dir, name.ID, name.Chapter)
+	}
+
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+func isCompanionFile(path string) bool {
+	return slices.Contains(companionExtensions, strings.ToLower(filepath.Ext(path)))
+}
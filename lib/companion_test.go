@@ -0,0 +1,30 @@
+package syncmediatrack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupCompanions(t *testing.T) {
+	paths := []string{
+		"a/GH010123.MP4", "a/GH010123.THM", "a/GL010123.LRV", "a/GH020123.MP4", "a/GL020123.LRV",
+		"a/IMG_0001.CR3", "a/IMG_0001.JPG", "b/IMG_0001.JPG",
+		"a/IMG_0002.HEIC", "a/IMG_0002.MOV",
+		"a/GL030123.LRV",
+	}
+
+	expected := []MediaGroup{
+		{Path: "a/GH010123.MP4", Companions: []string{"a/GH010123.THM", "a/GL010123.LRV"}},
+		{Path: "a/GH020123.MP4", Companions: []string{"a/GL020123.LRV"}},
+		// a companion file without its media is processed alone
+		{Path: "a/GL030123.LRV", Companions: []string{}},
+		{Path: "a/IMG_0001.CR3", Companions: []string{"a/IMG_0001.JPG"}},
+		{Path: "a/IMG_0002.HEIC", Companions: []string{"a/IMG_0002.MOV"}},
+		{Path: "b/IMG_0001.JPG", Companions: []string{}},
+	}
+
+	result := GroupCompanions(paths)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/barasher/go-exiftool"
@@ -31,31 +33,117 @@ func init() {
 	}
 }
 
-func GetMediaDate(filename string, gps *Trkpt) (time.Time, time.Time, time.Time, error) {
-	var atime, etime, gtime time.Time
+// Sources of the date of a media, from the most to the least reliable
+const (
+	DateSourceGPS      = "GPS"
+	DateSourceEXIF     = "EXIF"
+	DateSourceFilename = "filename"
+	DateSourceMtime    = "mtime"
+)
+
+// MediaDate has the dates of a media, its GPS position and the camera that recorded it
+type MediaDate struct {
+	Atime time.Time
+	Etime time.Time
+	Gtime time.Time
+	// Ftime is the date encoded in the filename, FtimeDateOnly is true when it has only the day
+	Ftime         time.Time
+	FtimeDateOnly bool
+	// Naive is true when Etime is the time of the camera clock in an unknown time zone
+	Naive bool
+	// Stime is Etime before the correction of the clock error of the profile
+	Stime time.Time
+	// Zone is the time zone of the dates stored by the camera: the stored offset, UTC for the
+	// videos of the phones, the time zone of the profile or of the GPS position, nil if unknown
+	Zone    *time.Location
+	GPS     Trkpt
+	Camera  Camera
+	Profile *CameraProfile
+}
+
+func GetMediaDate(filename string) (MediaDate, error) {
+	var media MediaDate
 
 	f, err := os.Stat(filename)
 	if err != nil {
-		return atime, etime, gtime, err
+		return media, err
 	}
-	atime = f.ModTime()
+	media.Atime = f.ModTime()
 
-	if FileIsVideo(filename) {
-		gtime = getTimeFromMP4(filename)
+	isVideo := FileIsVideo(filename)
+	if isVideo {
+		media.Gtime = getTimeFromMP4(filename)
 	}
 
-	// create an instance of exiftool
-	et, err := exiftool.NewExiftool(exiftool.CoordFormant("%+f"))
+	meta, err := extractMetadata(filename)
 	if err != nil {
-		return atime, etime, gtime, err
+		return media, err
 	}
-	defer et.Close()
 
-	metas := et.ExtractMetadata(filename)
+	gps := &media.GPS
+	gpsMeta := meta
+	readGPSPosition(meta, gps)
 
-	gps.Lon, _ = metas[0].GetFloat("GPSLongitude")
-	gps.Lat, _ = metas[0].GetFloat("GPSLatitude")
-	EleStr, err := metas[0].GetString("GPSAltitude")
+	// the position written in the sidecar replaces the position of the media
+	if sidecar, ok := FindSidecar(filename); ok {
+		sidecarMeta, err := extractMetadata(sidecar)
+		if err == nil && sidecarMeta.Err == nil && readGPSPosition(sidecarMeta, gps) {
+			gpsMeta = sidecarMeta
+		}
+	}
+
+	if gps.Lon != 0 && gps.Lat != 0 && media.Gtime.IsZero() {
+		t, err := gpsMeta.GetString("GPSDateTime")
+		if err == nil {
+			media.Gtime, _ = time.Parse("2006:01:02 15:04:05Z", t)
+			media.Gtime = UpdateGPSDateTime(media.Gtime, gps.Lat, gps.Lon)
+		}
+	}
+
+	media.Camera.Make, _ = meta.GetString("Make")
+	media.Camera.Model, _ = meta.GetString("Model")
+	media.Camera.Serial, _ = meta.GetString("SerialNumber")
+
+	etime, zone := getCameraDate(meta, isVideo, gps)
+	naive := zone == nil
+	media.Etime = etime
+	media.Stime = etime
+	media.Naive = naive && !etime.IsZero()
+	media.Zone = zone
+
+	media.Ftime, media.FtimeDateOnly = getFilenameDate(filename, media.Atime)
+
+	media.Profile = FindCameraProfile(filename, media.Camera)
+	if media.Profile != nil {
+		media.Atime = media.Profile.CorrectFileTime(media.Atime)
+		if !etime.IsZero() {
+			media.Etime = media.Profile.CorrectCameraTime(etime, naive)
+			media.Naive = media.Naive && media.Profile.location == nil
+			if naive && media.Profile.location != nil {
+				media.Stime = wallTime(etime, media.Profile.location)
+			}
+		}
+		if !media.Ftime.IsZero() {
+			media.Ftime = media.Profile.CorrectCameraTime(media.Ftime, true)
+		}
+		if media.Zone == nil {
+			media.Zone = media.Profile.location
+		}
+	}
+
+	if media.Zone == nil {
+		media.Zone = zoneAt(gps.Lat, gps.Lon)
+	}
+
+	return media, nil
+}
+
+// readGPSPosition reads the GPS position of the metadata, false if it has no position
+func readGPSPosition(meta exiftool.FileMetadata, gps *Trkpt) bool {
+	var position Trkpt
+	position.Lon, _ = meta.GetFloat("GPSLongitude")
+	position.Lat, _ = meta.GetFloat("GPSLatitude")
+	EleStr, err := meta.GetString("GPSAltitude")
 	if err == nil {
 		re := regexp.MustCompile(`(-?\d+(\.\d{1,4})?) m.*`)
 		match := re.FindStringSubmatch(EleStr)
@@ -63,82 +151,240 @@ func GetMediaDate(filename string, gps *Trkpt) (time.Time, time.Time, time.Time,
 		if len(match) > 1 {
 			alt, err := strconv.Atoi(match[1])
 			if err == nil {
-				gps.Ele = float64(alt)
+				position.Ele = float64(alt)
 			}
 		}
 	}
-	if gps.Lon != 0 && gps.Lat != 0 && gtime.IsZero() {
-		t, err := metas[0].GetString("GPSDateTime")
-		if err == nil {
-			gtime, _ = time.Parse("2006:01:02 15:04:05Z", t)
-			gtime = UpdateGPSDateTime(gtime, gps.Lat, gps.Lon)
+
+	if position.Lat == 0 && position.Lon == 0 {
+		return false
+	}
+
+	*gps = position
+
+	return true
+}
+
+// BestDate returns the most reliable date of the media and its source: the GPS, the camera,
+// the filename and the modification time of the file
+func (m MediaDate) BestDate() (time.Time, string) {
+	switch {
+	case !m.Gtime.IsZero():
+		return m.Gtime, DateSourceGPS
+	case !m.Etime.IsZero():
+		return m.Etime, DateSourceEXIF
+	case !m.Ftime.IsZero():
+		return m.Ftime, DateSourceFilename
+	}
+
+	return m.Atime, DateSourceMtime
+}
+
+// CameraDate returns the date given by the camera clock, from the metadata, the filename or the
+// modification time of the file, and if it is a time without time zone
+func (m MediaDate) CameraDate() (time.Time, bool) {
+	switch {
+	case !m.Etime.IsZero():
+		return m.Etime, m.Naive
+	case !m.Ftime.IsZero():
+		return m.Ftime, m.ftimeNaive()
+	}
+
+	return m.Atime, false
+}
+
+// BestInstant returns BestDate as an instant, a time without time zone is interpreted in Zone.
+// It returns false when the time zone is unknown or only the day is known
+func (m MediaDate) BestInstant() (time.Time, string, bool) {
+	date, source := m.BestDate()
+
+	switch {
+	case source == DateSourceFilename && m.FtimeDateOnly:
+		return date, source, false
+	case (source == DateSourceEXIF && m.Naive) || (source == DateSourceFilename && m.ftimeNaive()):
+		if m.Zone == nil {
+			return date, source, false
 		}
+		return wallTime(date, m.Zone), source, true
+	}
+
+	return date, source, true
+}
+
+// ftimeNaive checks if Ftime is the wall clock of the camera in an unknown time zone
+func (m MediaDate) ftimeNaive() bool {
+	return m.Profile == nil || m.Profile.location == nil
+}
+
+// getFilenameDate returns the date encoded in the filename and if it has only the day, a name
+// with only the day is ignored when the modification time is on the same day because it is
+// more precise
+func getFilenameDate(filename string, mtime time.Time) (time.Time, bool) {
+	name, ok := ParseFileName(filename)
+	if !ok || name.Time.IsZero() {
+		return time.Time{}, false
+	}
+
+	if name.DateOnly && mtime.Format("20060102") == name.Time.Format("20060102") {
+		return time.Time{}, false
 	}
 
-	// define the list of possible tags to extract date from
-	dateTags := []string{"DateTimeOriginal", "DateTime", "DateTimeDigitized"}
+	return name.Time, name.DateOnly
+}
 
+// getCameraDate returns the date stored by the camera and the time zone of the stored date, nil
+// if it is a time without time zone, then the date has the wall clock of the camera in UTC until
+// the time zone of the camera is known
+func getCameraDate(meta exiftool.FileMetadata, isVideo bool, gps *Trkpt) (time.Time, *time.Location) {
 	// loop through the tags until a valid date is found
-	for _, tag := range dateTags {
-		val, err := metas[0].GetString(tag)
+	for _, tag := range exifDateTags {
+		t, zoned, err := getExifDate(meta, tag)
 		if err != nil {
 			continue
 		}
-		if val != "" {
-			t, err := time.Parse("2006:01:02 15:04:05", val)
-			if err == nil {
-				return atime, t, gtime, nil
-			}
+		if !zoned {
+			return t, nil
+		}
+
+		_, offset := t.Zone()
+		return t, time.FixedZone("", offset)
+	}
+
+	if !isVideo {
+		return time.Time{}, nil
+	}
+
+	cameraMake, _ := meta.GetString("Make")
+	cameraModel, _ := meta.GetString("Model")
+
+	// QuickTime dates, UTC or local time depending on the camera
+	videoTags := []string{"CreateDate", "MediaCreateDate"}
+
+	for _, tag := range videoTags {
+		val, err := meta.GetString(tag)
+		if err != nil {
+			continue
+		}
+		t, err := time.Parse("2006:01:02 15:04:05", val)
+		if err != nil {
+			continue
+		}
+		if VideoIsUTC(cameraMake, cameraModel) {
+			return videoLocalTime(t, gps), time.UTC
+		}
+
+		return t, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// wallTime returns the instant when the clocks of the location show the date and time of t,
+// the result is not guaranteed in the hour skipped when the summer time starts
+func wallTime(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// WallTime returns the instant of a time without time zone, given as the wall clock in UTC, in
+// the time zone loc
+func WallTime(t time.Time, loc *time.Location) time.Time {
+	return wallTime(t, loc)
+}
+
+// exifDateTag groups a date tag with the tags that store its time zone offset and sub-seconds
+type exifDateTag struct {
+	Date   string
+	Offset string
+	SubSec string
+}
+
+// define the list of possible tags to extract date from, the composite tag of exiftool
+// already includes the offset and sub-seconds when the camera stores them
+var exifDateTags = []exifDateTag{
+	{Date: "SubSecDateTimeOriginal", Offset: "OffsetTimeOriginal"},
+	{Date: "DateTimeOriginal", Offset: "OffsetTimeOriginal", SubSec: "SubSecTimeOriginal"},
+	{Date: "DateTime", Offset: "OffsetTime", SubSec: "SubSecTime"},
+	{Date: "DateTimeDigitized", Offset: "OffsetTimeDigitized", SubSec: "SubSecTimeDigitized"},
+}
+
+var (
+	reExifOffset = regexp.MustCompile(`^[+-]\d{2}:\d{2}$`)
+	reExifZone   = regexp.MustCompile(`(Z|[+-]\d{2}:\d{2})$`)
+	reExifSubSec = regexp.MustCompile(`^\d+$`)
+)
+
+// getExifDate returns the date of the tag with its sub-seconds. When the offset is known the
+// result is an absolute instant and zoned is true, otherwise it is the time of the camera
+// without time zone
+func getExifDate(meta exiftool.FileMetadata, tag exifDateTag) (t time.Time, zoned bool, err error) {
+	val, err := meta.GetString(tag.Date)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	val = strings.TrimSpace(val)
+
+	if tag.SubSec != "" && !strings.Contains(val, ".") && !reExifZone.MatchString(val) {
+		subSec, err := meta.GetString(tag.SubSec)
+		if err == nil && reExifSubSec.MatchString(strings.TrimSpace(subSec)) {
+			val = fmt.Sprintf("%s.%s", val, strings.TrimSpace(subSec))
+		}
+	}
+
+	if !reExifZone.MatchString(val) {
+		offset, err := meta.GetString(tag.Offset)
+		if err == nil && reExifOffset.MatchString(strings.TrimSpace(offset)) {
+			val += strings.TrimSpace(offset)
 		}
 	}
 
-	return atime, etime, gtime, nil
+	// the fractional seconds are accepted after the seconds even if the layout does not have them
+	if reExifZone.MatchString(val) {
+		t, err = time.Parse("2006:01:02 15:04:05Z07:00", val)
+		return t, true, err
+	}
+
+	t, err = time.Parse("2006:01:02 15:04:05", val)
+	return t, false, err
 }
 
+// GetClosesGPS returns the track point closest in time to the media, false if there is no point
+// within MaxTime seconds
 func GetClosesGPS(imageTime time.Time, closestPoint *Trkpt) bool {
-	var closestDuration time.Duration
-	var oldtrkptTime time.Time
+	closestDuration := time.Duration(-1)
 	var closestFilename string
 
 	for filename, gpx := range DataGPX {
-		closestDuration = 0
-		oldtrkptTime = time.Time{}
+		points := gpx.Trk.Trkseg.Trkpt
+		if len(points) == 0 {
+			continue
+		}
 
-		first := GetTimeFromTrkpt(gpx.Trk.Trkseg.Trkpt[0]).Add(-MaxTime * time.Second)
-		last := GetTimeFromTrkpt(gpx.Trk.Trkseg.Trkpt[len(gpx.Trk.Trkseg.Trkpt)-1]).Add(MaxTime * time.Second)
+		first := GetTimeFromTrkpt(points[0]).Add(-MaxTime * time.Second)
+		last := GetTimeFromTrkpt(points[len(points)-1]).Add(MaxTime * time.Second)
 
 		if !isBetween(imageTime, first, last) {
 			continue
 		}
 
-		for _, trkpt := range gpx.Trk.Trkseg.Trkpt {
+		for _, trkpt := range points {
 			trkptTime := GetTimeFromTrkpt(trkpt)
 			if trkptTime.IsZero() {
 				continue
 			}
 
-			duration := imageTime.Sub(trkptTime)
-			if duration < 0 {
-				duration = -duration
-			}
-
-			if closestDuration == 0 || duration < closestDuration {
+			duration := imageTime.Sub(trkptTime).Abs()
+			if closestDuration < 0 || duration < closestDuration {
 				*closestPoint = trkpt
 				closestDuration = duration
 				closestFilename = filename
+			} else if trkptTime.After(imageTime) {
+				// the points are in order, the next ones are further
+				break
 			}
-
-			if isBetween(imageTime, oldtrkptTime, trkptTime) {
-				if Verbose {
-					fmt.Printf(" Diff.sec (%.0f [%s]) ", closestDuration.Seconds(), filename)
-				}
-				return true
-			}
-			oldtrkptTime = trkptTime
 		}
 	}
 
-	if oldtrkptTime.IsZero() {
+	if closestDuration < 0 {
 		return false
 	}
 
@@ -146,39 +392,39 @@ func GetClosesGPS(imageTime time.Time, closestPoint *Trkpt) bool {
 		fmt.Printf(" Diff.sec (%.0f [%s]) ", closestDuration.Seconds(), closestFilename)
 	}
 
-	if closestDuration.Seconds() > MaxTime {
-		return false
-	}
-
-	return true
+	return closestDuration.Seconds() <= MaxTime
 }
 
+// isBetween checks if the instant is between start and end, both included
 func isBetween(date, start, end time.Time) bool {
 	if start.IsZero() || end.IsZero() {
 		return false
 	}
 
-	// remove timezone from time
-	date, _ = time.Parse("2006-01-02 15:04:05", date.Format("2006-01-02 15:04:05"))
-
-	return (date.Equal(start) || date.After(start)) && (date.Equal(end) || date.Before(end))
+	return !date.Before(start) && !date.After(end)
 }
 
+// WriteGPS writes the GPS position in the media, or in its sidecar, and reads it back to check it
 func WriteGPS(gps Trkpt, filename string) error {
-	et, err := exiftool.NewExiftool()
-	if err != nil {
-		return err
+	written := filename
+	write := writeMediaGPS
+	if Sidecar {
+		written = SidecarName(filename)
+		write = writeSidecarGPS
 	}
-	defer et.Close()
 
-	// Extract file metadata
-	fileInfos := et.ExtractMetadata(filename)
-	if len(fileInfos) == 0 {
-		return fmt.Errorf("no metadata found %s", filename)
+	if err := write(gps, filename); err != nil {
+		return fmt.Errorf("the GPS position could not be written in %s: %w", written, err)
 	}
-	fileInfo := fileInfos[0]
-	if fileInfo.Err != nil {
-		return fileInfo.Err
+
+	return verifyGPS(written, gps)
+}
+
+// writeMediaGPS writes only the GPS tags in the media, the other tags are not rewritten
+func writeMediaGPS(gps Trkpt, filename string) error {
+	et, err := getExiftool()
+	if err != nil {
+		return err
 	}
 
 	latRef := "South"
@@ -190,13 +436,17 @@ func WriteGPS(gps Trkpt, filename string) error {
 		lonRef = "East"
 	}
 
-	gpsTime, err := time.Parse("2006-01-02T15:04:05Z", gps.Time)
+	gpsTime, err := ParseTrkptTime(gps.Time)
 	if err != nil {
-		log.Fatal(ColorRed(err))
+		return fmt.Errorf("invalid GPS time %s: %w", gps.Time, err)
 	}
 
-	fileInfo.SetString("GPSDateStamp", gpsTime.Format("2006:01:02"))
-	fileInfo.SetString("GPSTimeStamp", gpsTime.Format("15:04:05,00"))
+	fileInfo := exiftool.EmptyFileMetadata()
+	fileInfo.File = filename
+
+	dateStamp, timeStamp := gpsStamps(gpsTime)
+	fileInfo.SetString("GPSDateStamp", dateStamp)
+	fileInfo.SetString("GPSTimeStamp", timeStamp)
 
 	// Update latitude, longitude, and elevation values
 	fileInfo.SetFloat("GPSLatitude", gps.Lat)
@@ -208,11 +458,171 @@ func WriteGPS(gps Trkpt, filename string) error {
 	fileInfo.SetString("GPSLongitudeRef", lonRef)
 
 	// Write the new metadata to the file.
-	et.WriteMetadata([]exiftool.FileMetadata{fileInfo})
+	return recordChange(filename, gpsTags, func() error {
+		fileInfos := []exiftool.FileMetadata{fileInfo}
+		et.WriteMetadata(fileInfos)
+
+		return fileInfos[0].Err
+	})
+}
+
+// Maximum difference in degrees between the written and the read coordinates, exiftool rounds them
+const maxCoordDiff = 1e-5
+
+// verifyGPS reads the GPS position of the file and checks that it is the written one
+func verifyGPS(filename string, gps Trkpt) error {
+	et, err := getExiftool()
+	if err != nil {
+		return err
+	}
+
+	metas := et.ExtractMetadata(filename)
+	if len(metas) == 0 {
+		return fmt.Errorf("no metadata found %s", filename)
+	}
+	if metas[0].Err != nil {
+		return metas[0].Err
+	}
+
+	var written Trkpt
+	if !readGPSPosition(metas[0], &written) {
+		return fmt.Errorf("the GPS position has not been written in %s", filename)
+	}
+
+	if math.Abs(written.Lat-gps.Lat) > maxCoordDiff || math.Abs(written.Lon-gps.Lon) > maxCoordDiff {
+		return fmt.Errorf("the GPS position of %s is Lat %v Lon %v instead of Lat %v Lon %v", filename, written.Lat, written.Lon, gps.Lat, gps.Lon)
+	}
 
 	return nil
 }
 
+// GPS tags written in the medias
+var gpsTags = []string{
+	"GPSDateStamp", "GPSTimeStamp", "GPSLatitude", "GPSLongitude", "GPSAltitude", "GPSAltitudeRef",
+	"GPSLatitudeRef", "GPSLongitudeRef",
+}
+
+// gpsStamps returns the GPS date and time stamps, they are always in UTC
+func gpsStamps(t time.Time) (string, string) {
+	t = t.UTC()
+
+	return t.Format("2006:01:02"), t.Format("15:04:05.00")
+}
+
+// Dates stored by the camera that are moved when its clock is corrected, with the tags of their
+// time zone offset
+var shiftDateTags = []exifDateTag{
+	{Date: "DateTimeOriginal", Offset: "OffsetTimeOriginal"},
+	{Date: "CreateDate", Offset: "OffsetTimeDigitized"},
+	{Date: "ModifyDate", Offset: "OffsetTime"},
+}
+
+// ShiftMediaDates subtracts the clock error from the dates stored by the camera and returns the
+// tags that have been updated. The dates without time zone are read and written as the wall
+// clock of loc, with its time zone offset, or they are moved as they are when loc is nil
+func ShiftMediaDates(filename string, clockError time.Duration, loc *time.Location) ([]string, error) {
+	et, err := getExiftool()
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfos := et.ExtractMetadata(filename)
+	if len(fileInfos) == 0 {
+		return nil, fmt.Errorf("no metadata found %s", filename)
+	}
+	if fileInfos[0].Err != nil {
+		return nil, fileInfos[0].Err
+	}
+
+	fileInfo := exiftool.EmptyFileMetadata()
+	fileInfo.File = filename
+
+	var updated []string
+	for _, tag := range shiftDateTags {
+		val, err := fileInfos[0].GetString(tag.Date)
+		if err != nil || len(val) < 19 {
+			continue
+		}
+
+		t, err := time.Parse("2006:01:02 15:04:05", val[:19])
+		if err != nil {
+			continue
+		}
+
+		// keep the sub-seconds or time zone after the date
+		suffix := val[19:]
+		if loc == nil || reExifZone.MatchString(suffix) {
+			updated = append(updated, setDateTag(&fileInfo, tag, t.Add(-clockError), suffix, false)...)
+			continue
+		}
+
+		t = wallTime(t, loc).Add(-clockError).In(loc)
+		updated = append(updated, setDateTag(&fileInfo, tag, t, suffix, !FileIsVideo(filename))...)
+	}
+
+	if len(updated) == 0 {
+		return nil, nil
+	}
+
+	err = recordChange(filename, updated, func() error {
+		fileInfos := []exiftool.FileMetadata{fileInfo}
+		et.WriteMetadata(fileInfos)
+
+		return fileInfos[0].Err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// SetMediaDates replaces the dates stored by the camera with the wall clock of t in loc, with its
+// time zone offset, or with t as it is when loc is nil and returns the tags that have been updated
+func SetMediaDates(filename string, t time.Time, loc *time.Location) ([]string, error) {
+	et, err := getExiftool()
+	if err != nil {
+		return nil, err
+	}
+
+	fileInfo := exiftool.EmptyFileMetadata()
+	fileInfo.File = filename
+
+	if loc != nil {
+		t = t.In(loc)
+	}
+
+	var updated []string
+	for _, tag := range shiftDateTags {
+		updated = append(updated, setDateTag(&fileInfo, tag, t, "", loc != nil && !FileIsVideo(filename))...)
+	}
+
+	err = recordChange(filename, updated, func() error {
+		fileInfos := []exiftool.FileMetadata{fileInfo}
+		et.WriteMetadata(fileInfos)
+
+		return fileInfos[0].Err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// setDateTag sets the date tag to the wall clock of t followed by suffix, and its time zone offset
+// tag when offset is true. It returns the tags that have been set
+func setDateTag(fileInfo *exiftool.FileMetadata, tag exifDateTag, t time.Time, suffix string, offset bool) []string {
+	fileInfo.SetString(tag.Date, t.Format("2006:01:02 15:04:05")+suffix)
+	if !offset {
+		return []string{tag.Date}
+	}
+
+	fileInfo.SetString(tag.Offset, t.Format("-07:00"))
+
+	return []string{tag.Date, tag.Offset}
+}
+
 func getTimeFromMP4(videoPath string) time.Time {
 	vman := videomanipulation.New()
 	data, err := vman.ExtractGPMF(videoPath)
@@ -257,20 +667,29 @@ func getTimeFromMP4(videoPath string) time.Time {
 }
 
 func UpdateGPSDateTime(gpsDateTime time.Time, lat float64, lon float64) time.Time {
-	if lat == 0 && lon == 0 {
+	loc := zoneAt(lat, lon)
+	if loc == nil {
 		return gpsDateTime
 	}
 
-	zone := finder.GetTimezoneName(lon, lat)
+	return gpsDateTime.In(loc)
+}
+
+// zoneAt returns the time zone of the position, nil if it is unknown
+func zoneAt(lat float64, lon float64) *time.Location {
+	if lat == 0 && lon == 0 {
+		return nil
+	}
 
+	zone := finder.GetTimezoneName(lon, lat)
 	if zone == "" {
-		return gpsDateTime
+		return nil
 	}
 
 	loc, err := time.LoadLocation(zone)
 	if err != nil {
-		return gpsDateTime
+		return nil
 	}
 
-	return gpsDateTime.In(loc)
+	return loc
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/barasher/go-exiftool"
 	"github.com/ringsaturn/tzf"
 )
 
@@ -47,3 +48,312 @@ func TestUpdateGPSDateTime(t *testing.T) {
 		}
 	})
 }
+
+func TestGetExifDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		tag      exifDateTag
+		expected string
+	}{
+		{
+			name:     "naive",
+			fields:   map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12"},
+			tag:      exifDateTags[1],
+			expected: "2023-03-26T09:59:12Z",
+		},
+		{
+			name:     "offset",
+			fields:   map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12", "OffsetTimeOriginal": "+02:00"},
+			tag:      exifDateTags[1],
+			expected: "2023-03-26T07:59:12Z",
+		},
+		{
+			name:     "sub-seconds and offset",
+			fields:   map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12", "SubSecTimeOriginal": "045", "OffsetTimeOriginal": "-05:00"},
+			tag:      exifDateTags[1],
+			expected: "2023-03-26T14:59:12.045Z",
+		},
+		{
+			name:     "numeric sub-seconds",
+			fields:   map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12", "SubSecTimeOriginal": float64(12)},
+			tag:      exifDateTags[1],
+			expected: "2023-03-26T09:59:12.12Z",
+		},
+		{
+			name:     "composite",
+			fields:   map[string]interface{}{"SubSecDateTimeOriginal": "2023:03:26 09:59:12.50+01:00"},
+			tag:      exifDateTags[0],
+			expected: "2023-03-26T08:59:12.5Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := getExifDate(exiftool.FileMetadata{Fields: tt.fields}, tt.tag)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if result.UTC().Format(time.RFC3339Nano) != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result.UTC().Format(time.RFC3339Nano))
+			}
+		})
+	}
+
+	_, _, err := getExifDate(exiftool.FileMetadata{Fields: map[string]interface{}{"DateTimeOriginal": "0000:00:00 00:00:00"}}, exifDateTags[1])
+	if err == nil {
+		t.Errorf("Expected an error with an empty date")
+	}
+}
+
+func TestMediaBestDate(t *testing.T) {
+	atime := time.Date(2023, 3, 27, 18, 0, 0, 0, time.UTC)
+	ftime := time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)
+	etime := ftime.Add(time.Minute)
+	gtime := ftime.Add(2 * time.Minute)
+
+	tests := []struct {
+		media    MediaDate
+		expected time.Time
+		source   string
+	}{
+		{MediaDate{Atime: atime}, atime, DateSourceMtime},
+		{MediaDate{Atime: atime, Ftime: ftime}, ftime, DateSourceFilename},
+		{MediaDate{Atime: atime, Ftime: ftime, Etime: etime}, etime, DateSourceEXIF},
+		{MediaDate{Atime: atime, Ftime: ftime, Etime: etime, Gtime: gtime}, gtime, DateSourceGPS},
+	}
+
+	for _, tt := range tests {
+		date, source := tt.media.BestDate()
+		if !date.Equal(tt.expected) || source != tt.source {
+			t.Errorf("Expected %v from %s, got %v from %s", tt.expected, tt.source, date, source)
+		}
+	}
+}
+
+func TestMediaBestInstant(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	atime := time.Date(2023, 3, 27, 18, 0, 0, 0, time.UTC)
+	wall := time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)
+	local := time.Date(2023, 3, 26, 9, 59, 12, 0, madrid)
+
+	tests := []struct {
+		name     string
+		media    MediaDate
+		expected time.Time
+		ok       bool
+	}{
+		{"mtime", MediaDate{Atime: atime}, atime, true},
+		{"naive in the time zone of the position", MediaDate{Atime: atime, Etime: wall, Naive: true, Zone: madrid}, local, true},
+		{"naive without time zone", MediaDate{Atime: atime, Etime: wall, Naive: true}, wall, false},
+		{"with offset", MediaDate{Atime: atime, Etime: local, Zone: madrid}, local, true},
+		{"filename", MediaDate{Atime: atime, Ftime: wall, Zone: madrid}, local, true},
+		{"filename with only the day", MediaDate{Atime: atime, Ftime: wall, FtimeDateOnly: true, Zone: madrid}, wall, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, _, ok := tt.media.BestInstant()
+			if !date.Equal(tt.expected) || ok != tt.ok {
+				t.Errorf("Expected %v %v, got %v %v", tt.expected, tt.ok, date, ok)
+			}
+		})
+	}
+}
+
+func TestGetFilenameDate(t *testing.T) {
+	mtime := time.Date(2023, 3, 26, 18, 0, 0, 0, time.UTC)
+
+	if date, dateOnly := getFilenameDate("VID_20230326_095912.mp4", mtime); dateOnly || !date.Equal(time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)) {
+		t.Errorf("Unexpected date %v", date)
+	}
+
+	// the modification time is more precise than a date without time
+	if date, _ := getFilenameDate("IMG-20230326-WA0001.jpg", mtime); !date.IsZero() {
+		t.Errorf("Expected no date, got %v", date)
+	}
+
+	if date, dateOnly := getFilenameDate("IMG-20230320-WA0001.jpg", mtime); !dateOnly || !date.Equal(time.Date(2023, 3, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date %v", date)
+	}
+}
+
+func TestIsBetween(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	start := time.Date(2023, 10, 28, 22, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 10, 29, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		expected bool
+	}{
+		{"utc", time.Date(2023, 10, 28, 23, 0, 0, 0, time.UTC), true},
+		{"after midnight in Madrid", time.Date(2023, 10, 29, 0, 30, 0, 0, madrid), true},
+		{"before midnight in New York", time.Date(2023, 10, 28, 20, 0, 0, 0, newYork), true},
+		{"start", start.In(madrid), true},
+		{"end", end.In(newYork), true},
+		{"repeated hour in summer time", time.Date(2023, 10, 29, 2, 30, 0, 0, time.FixedZone("CEST", 2*3600)), true},
+		{"repeated hour in winter time", time.Date(2023, 10, 29, 2, 30, 0, 0, time.FixedZone("CET", 3600)), false},
+		{"same wall clock in other zone", time.Date(2023, 10, 28, 23, 0, 0, 0, newYork), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isBetween(tt.date, start, end); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetClosesGPS(t *testing.T) {
+	defer func(data map[string]Gpx) { DataGPX = data }(DataGPX)
+
+	// a track point every minute around the end of the summer time in Europe
+	start := time.Date(2023, 10, 28, 22, 0, 0, 0, time.UTC)
+	var gpx Gpx
+	for i := 0; i <= 300; i++ {
+		gpx.Trk.Trkseg.Trkpt = append(gpx.Trk.Trkseg.Trkpt, Trkpt{
+			Lat:  40 + float64(i)/1000,
+			Lon:  -3.7,
+			Time: FormatTrkptTime(start.Add(time.Duration(i) * time.Minute)),
+		})
+	}
+	DataGPX = map[string]Gpx{"track.gpx": gpx}
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		expected string
+	}{
+		{"utc", time.Date(2023, 10, 29, 0, 30, 0, 0, time.UTC), "2023-10-29T00:30:00Z"},
+		{"Madrid before midnight", time.Date(2023, 10, 29, 0, 10, 0, 0, time.FixedZone("CEST", 2*3600)), "2023-10-28T22:10:00Z"},
+		{"Madrid summer time", time.Date(2023, 10, 29, 2, 30, 0, 0, time.FixedZone("CEST", 2*3600)), "2023-10-29T00:30:00Z"},
+		{"Madrid winter time", time.Date(2023, 10, 29, 2, 30, 0, 0, time.FixedZone("CET", 3600)), "2023-10-29T01:30:00Z"},
+		{"New York", time.Date(2023, 10, 28, 20, 30, 20, 0, time.FixedZone("EDT", -4*3600)), "2023-10-29T00:30:00Z"},
+		{"Tokyo", time.Date(2023, 10, 29, 9, 0, 40, 0, time.FixedZone("JST", 9*3600)), "2023-10-29T00:01:00Z"},
+		{"out of the track", time.Date(2023, 10, 29, 5, 0, 0, 0, time.UTC), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var closest Trkpt
+			found := GetClosesGPS(tt.date, &closest)
+
+			if found != (tt.expected != "") || (found && closest.Time != tt.expected) {
+				t.Errorf("Expected %q, got %v %q", tt.expected, found, closest.Time)
+			}
+		})
+	}
+}
+
+func TestGPSStamps(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		date      time.Time
+		dateStamp string
+		timeStamp string
+	}{
+		{time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC), "2023:03:26", "09:59:12.00"},
+		{time.Date(2023, 3, 26, 1, 30, 0, 0, madrid), "2023:03:26", "00:30:00.00"},
+		{time.Date(2023, 3, 26, 3, 30, 0, 0, madrid), "2023:03:26", "01:30:00.00"},
+		{time.Date(2023, 1, 1, 0, 30, 0, 500000000, madrid), "2022:12:31", "23:30:00.50"},
+		{time.Date(2023, 7, 1, 21, 0, 0, 0, time.FixedZone("EDT", -4*3600)), "2023:07:02", "01:00:00.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date.String(), func(t *testing.T) {
+			dateStamp, timeStamp := gpsStamps(tt.date)
+			if dateStamp != tt.dateStamp || timeStamp != tt.timeStamp {
+				t.Errorf("Expected %s %s, got %s %s", tt.dateStamp, tt.timeStamp, dateStamp, timeStamp)
+			}
+		})
+	}
+}
+
+func TestGetCameraDateZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		video    bool
+		expected string
+		offset   int
+		naive    bool
+	}{
+		{"naive", map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12"}, false, "2023-03-26T09:59:12Z", 0, true},
+		{"offset", map[string]interface{}{"DateTimeOriginal": "2023:03:26 09:59:12", "OffsetTimeOriginal": "+02:00"}, false, "2023-03-26T07:59:12Z", 2 * 3600, false},
+		{"phone video", map[string]interface{}{"Make": "Apple", "CreateDate": "2023:03:26 07:59:12"}, true, "2023-03-26T07:59:12Z", 0, false},
+		{"camera video", map[string]interface{}{"Make": "Canon", "CreateDate": "2023:03:26 09:59:12"}, true, "2023-03-26T09:59:12Z", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, zone := getCameraDate(exiftool.FileMetadata{Fields: tt.fields}, tt.video, &Trkpt{})
+
+			if result.UTC().Format(time.RFC3339) != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result.UTC().Format(time.RFC3339))
+			}
+
+			if (zone == nil) != tt.naive {
+				t.Fatalf("Expected naive %v, got zone %v", tt.naive, zone)
+			}
+			if zone != nil {
+				if _, offset := result.In(zone).Zone(); offset != tt.offset {
+					t.Errorf("Expected offset %d, got %d", tt.offset, offset)
+				}
+			}
+		})
+	}
+}
+
+func TestSetDateTag(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// a camera 1 minute behind in Madrid, the date is shifted across the change to summer time
+	stored := time.Date(2023, 3, 26, 1, 59, 40, 0, time.UTC)
+	shifted := wallTime(stored, madrid).Add(time.Minute).In(madrid)
+
+	fileInfo := exiftool.EmptyFileMetadata()
+	tags := setDateTag(&fileInfo, shiftDateTags[0], shifted, ".50", true)
+	if len(tags) != 2 || tags[1] != "OffsetTimeOriginal" {
+		t.Errorf("Expected the date and offset tags, got %v", tags)
+	}
+
+	if value, _ := fileInfo.GetString("DateTimeOriginal"); value != "2023:03:26 03:00:40.50" {
+		t.Errorf("Expected 2023:03:26 03:00:40.50, got %s", value)
+	}
+	if value, _ := fileInfo.GetString("OffsetTimeOriginal"); value != "+02:00" {
+		t.Errorf("Expected +02:00, got %s", value)
+	}
+
+	// the videos have no offset tags
+	fileInfo = exiftool.EmptyFileMetadata()
+	tags = setDateTag(&fileInfo, shiftDateTags[1], shifted.UTC(), "", false)
+	if len(tags) != 1 {
+		t.Errorf("Expected only the date tag, got %v", tags)
+	}
+	if value, _ := fileInfo.GetString("CreateDate"); value != "2023:03:26 01:00:40" {
+		t.Errorf("Expected 2023:03:26 01:00:40, got %s", value)
+	}
+}
@@ -0,0 +1,136 @@
+package syncmediatrack
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/barasher/go-exiftool"
+)
+
+var (
+	// MetadataBatch is the number of medias whose metadata is read by exiftool at once
+	MetadataBatch = 50
+	// ExiftoolProcesses is the number of exiftool processes shared by the reads and writes
+	ExiftoolProcesses = 1
+
+	// exiftool processes are started when they are needed and used in turn
+	sessions     []*exiftool.Exiftool
+	nextSession  int
+	sessionMutex sync.Mutex
+	// exiftool without print conversion, the values are read and written exactly
+	rawSession *exiftool.Exiftool
+
+	// metadata read in advance by PrefetchMetadata, it is removed when it is used
+	metadataCache = map[string]exiftool.FileMetadata{}
+	cacheMutex    sync.Mutex
+)
+
+// getExiftool returns the next shared exiftool, each exiftool runs one command at a time
+func getExiftool() (*exiftool.Exiftool, error) {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	if len(sessions) < max(ExiftoolProcesses, 1) {
+		et, err := exiftool.NewExiftool(exiftool.CoordFormant("%+f"))
+		if err != nil {
+			return nil, fmt.Errorf("exiftool could not be started: %w", err)
+		}
+		sessions = append(sessions, et)
+
+		return et, nil
+	}
+
+	nextSession = (nextSession + 1) % len(sessions)
+
+	return sessions[nextSession], nil
+}
+
+// getRawExiftool returns the shared exiftool that reads and writes the values without print conversion
+func getRawExiftool() (*exiftool.Exiftool, error) {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	if rawSession == nil {
+		et, err := exiftool.NewExiftool(exiftool.NoPrintConversion())
+		if err != nil {
+			return nil, fmt.Errorf("exiftool could not be started: %w", err)
+		}
+		rawSession = et
+	}
+
+	return rawSession, nil
+}
+
+// CloseExiftool stops the shared exiftool processes, they are started again if they are needed later
+func CloseExiftool() error {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	cacheMutex.Lock()
+	clear(metadataCache)
+	cacheMutex.Unlock()
+
+	var errs []error
+	for _, et := range sessions {
+		errs = append(errs, et.Close())
+	}
+	sessions = nil
+	nextSession = 0
+
+	if rawSession != nil {
+		errs = append(errs, rawSession.Close())
+		rawSession = nil
+	}
+
+	return errors.Join(errs...)
+}
+
+// PrefetchMetadata reads the metadata of the medias with a single call to exiftool, GetMediaDate
+// uses it instead of reading each media
+func PrefetchMetadata(filenames []string) error {
+	if len(filenames) == 0 {
+		return nil
+	}
+
+	et, err := getExiftool()
+	if err != nil {
+		return err
+	}
+
+	metas := et.ExtractMetadata(filenames...)
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	for _, meta := range metas {
+		metadataCache[meta.File] = meta
+	}
+
+	return nil
+}
+
+// extractMetadata returns the metadata of the media read in advance or reads it, the error of
+// exiftool with the media is kept in the metadata
+func extractMetadata(filename string) (exiftool.FileMetadata, error) {
+	cacheMutex.Lock()
+	meta, ok := metadataCache[filename]
+	delete(metadataCache, filename)
+	cacheMutex.Unlock()
+
+	if ok {
+		return meta, nil
+	}
+
+	et, err := getExiftool()
+	if err != nil {
+		return exiftool.FileMetadata{}, err
+	}
+
+	metas := et.ExtractMetadata(filename)
+	if len(metas) == 0 {
+		return exiftool.FileMetadata{}, fmt.Errorf("no metadata found %s", filename)
+	}
+
+	return metas[0], nil
+}
@@ -0,0 +1,32 @@
+package syncmediatrack
+
+import (
+	"testing"
+
+	"github.com/barasher/go-exiftool"
+)
+
+func TestExtractMetadataCache(t *testing.T) {
+	meta := exiftool.EmptyFileMetadata()
+	meta.File = "photos/IMG_0001.JPG"
+	meta.SetString("Model", "Canon EOS R6")
+
+	cacheMutex.Lock()
+	metadataCache[meta.File] = meta
+	cacheMutex.Unlock()
+
+	got, err := extractMetadata(meta.File)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if model, _ := got.GetString("Model"); model != "Canon EOS R6" {
+		t.Errorf("Expected model %q, got %q", "Canon EOS R6", model)
+	}
+
+	cacheMutex.Lock()
+	_, ok := metadataCache[meta.File]
+	cacheMutex.Unlock()
+	if ok {
+		t.Errorf("Expected %s to be removed from the cache", meta.File)
+	}
+}
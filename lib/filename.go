@@ -0,0 +1,165 @@
+package syncmediatrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the information encoded in the name of a media by the camera
+type FileName struct {
+	Scheme string
+	// ID is the sequence ID of the media, the chapters of a video share it
+	ID      string
+	Number  int64
+	Chapter int
+	// Time is the date encoded in the name, zero if there is none
+	Time time.Time
+	// DateOnly is true when the name has the date without the time of the day
+	DateOnly bool
+}
+
+// FileNameScheme recognizes the names of the files of a camera with a regular expression, the
+// named groups are "id" for the sequence number, "chapter" for the chapter of a video and "time"
+// for the date, that is read with Layout
+type FileNameScheme struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Layout  string `json:"layout,omitempty"`
+
+	re *regexp.Regexp
+}
+
+var (
+	// FileNameSchemes are the schemes defined by the user, they are checked before the default ones
+	FileNameSchemes []FileNameScheme
+
+	defaultFileNameSchemes = []FileNameScheme{
+		// GoPro HERO6 and later: GH010123.MP4, GX020123.MP4 (HEVC), GL010123.LRV (low resolution)
+		{Name: "GoPro", Pattern: `^G[HXL](?P<chapter>\d{2})(?P<id>\d{4})\.\w+$`},
+		// GoPro HERO5 and earlier: GOPR0123.MP4 for the first chapter and GP010123.MP4 for the next ones
+		{Name: "GoPro", Pattern: `^GOPR(?P<id>\d{4})\.\w+$`},
+		{Name: "GoPro", Pattern: `^GP(?P<chapter>\d{2})(?P<id>\d{4})\.\w+$`},
+		// Google Pixel: PXL_20230326_095912123.jpg
+		{Name: "Pixel", Pattern: `^PXL_(?P<time>\d{8}_\d{6})\d{3}(?:\.\w+)*$`, Layout: "20060102_150405"},
+		// DJI: DJI_20230326095912_0001_D.JPG and DJI_0001.JPG
+		{Name: "DJI", Pattern: `^DJI_(?P<time>\d{14})_(?P<id>\d{4})(?:_\w+)?\.\w+$`, Layout: "20060102150405"},
+		{Name: "DJI", Pattern: `^DJI_(?P<id>\d{4})\.\w+$`},
+		// WhatsApp: IMG-20230326-WA0001.jpg, VID-20230326-WA0001.mp4, the counter restarts every day
+		{Name: "WhatsApp", Pattern: `^(?:IMG|VID|AUD|PTT)-(?P<time>\d{8})-WA\d{4}\.\w+$`, Layout: "20060102"},
+		// Android screenshots: Screenshot_2023-03-26-09-59-12.png, Screenshot_20230326-095912.png
+		{Name: "Screenshot", Pattern: `^Screenshot_(?P<time>\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2}).*\.\w+$`, Layout: "2006-01-02-15-04-05"},
+		{Name: "Screenshot", Pattern: `^Screenshot_(?P<time>\d{8}-\d{6}).*\.\w+$`, Layout: "20060102-150405"},
+		// Android: IMG_20230326_095912.jpg, VID_20230326_095912.mp4, PANO_20230326_095912.jpg
+		{Name: "Android", Pattern: `^(?:IMG|VID|PANO|MVIMG)_(?P<time>\d{8}_\d{6})\w*(?:~\d+)?\.\w+$`, Layout: "20060102_150405"},
+		// Canon, iPhone and many others: IMG_1234.JPG, MVI_1234.MOV, _MG_1234.CR2
+		{Name: "Canon", Pattern: `^(?:IMG|MVI|_MG)_(?P<id>\d{4})\.\w+$`},
+		// Sony DSC01234.ARW, Nikon DSC_1234.NEF and Fujifilm DSCF1234.RAF
+		{Name: "Sony", Pattern: `^_?DSC(?P<id>\d{5})\.\w+$`},
+		{Name: "Nikon", Pattern: `^_?DSC_(?P<id>\d{4})\.\w+$`},
+		{Name: "Fujifilm", Pattern: `^_?DSCF(?P<id>\d{4})\.\w+$`},
+		// Any name ending with a number
+		{Name: "generic", Pattern: `(?P<id>\d[\w.-]*)\.\w+$`},
+	}
+)
+
+func init() {
+	for i := range defaultFileNameSchemes {
+		defaultFileNameSchemes[i].re = regexp.MustCompile(defaultFileNameSchemes[i].Pattern)
+	}
+}
+
+// LoadFileNameSchemes reads a JSON file with a list of filename schemes
+func LoadFileNameSchemes(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var schemes []FileNameScheme
+	if err := json.Unmarshal(data, &schemes); err != nil {
+		return fmt.Errorf("filename schemes %s could not be processed, error: %w", filename, err)
+	}
+
+	for i := range schemes {
+		schemes[i].re, err = regexp.Compile(schemes[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("filename scheme %s: %w", schemes[i].Name, err)
+		}
+
+		if schemes[i].re.SubexpIndex("id") < 0 && schemes[i].re.SubexpIndex("time") < 0 {
+			return fmt.Errorf("filename scheme %s needs an id or time group", schemes[i].Name)
+		}
+	}
+
+	FileNameSchemes = append(FileNameSchemes, schemes...)
+
+	return nil
+}
+
+// ParseFileName returns the information encoded in the name of the media by the first scheme
+// that recognizes it
+func ParseFileName(filename string) (FileName, bool) {
+	base := filepath.Base(filename)
+
+	for _, schemes := range [][]FileNameScheme{FileNameSchemes, defaultFileNameSchemes} {
+		for _, scheme := range schemes {
+			name, ok := scheme.parse(base)
+			if ok {
+				return name, true
+			}
+		}
+	}
+
+	return FileName{}, false
+}
+
+func (s FileNameScheme) parse(base string) (FileName, bool) {
+	match := s.re.FindStringSubmatch(base)
+	if match == nil {
+		return FileName{}, false
+	}
+
+	name := FileName{Scheme: s.Name}
+
+	if i := s.re.SubexpIndex("time"); i >= 0 && match[i] != "" && s.Layout != "" {
+		t, err := time.Parse(s.Layout, match[i])
+		if err == nil {
+			name.Time = t
+			name.DateOnly = !strings.Contains(s.Layout, "15")
+		}
+	}
+
+	if i := s.re.SubexpIndex("id"); i >= 0 && match[i] != "" {
+		name.ID = match[i]
+	} else if i := s.re.SubexpIndex("time"); i >= 0 {
+		// without a counter the date orders the medias
+		name.ID = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, match[i])
+	}
+
+	if name.ID == "" {
+		return FileName{}, false
+	}
+
+	number, err := strconv.ParseInt(reSequenceNumber.FindString(name.ID), 10, 64)
+	if err != nil {
+		return FileName{}, false
+	}
+	name.Number = number
+
+	if i := s.re.SubexpIndex("chapter"); i >= 0 && match[i] != "" {
+		name.Chapter, _ = strconv.Atoi(match[i])
+	}
+
+	return name, true
+}
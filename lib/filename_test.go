@@ -0,0 +1,74 @@
+package syncmediatrack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		filename string
+		scheme   string
+		id       string
+		chapter  int
+		time     time.Time
+	}{
+		{"IMG_1234.JPG", "Canon", "1234", 0, time.Time{}},
+		{"_MG_1234.CR2", "Canon", "1234", 0, time.Time{}},
+		{"DSC01234.ARW", "Sony", "01234", 0, time.Time{}},
+		{"DSC_1234.NEF", "Nikon", "1234", 0, time.Time{}},
+		{"DSCF1234.RAF", "Fujifilm", "1234", 0, time.Time{}},
+		{"DJI_0001.JPG", "DJI", "0001", 0, time.Time{}},
+		{"DJI_20230326095912_0001_D.JPG", "DJI", "0001", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"PXL_20230326_095912123.jpg", "Pixel", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"PXL_20230326_095912123.MP.jpg", "Pixel", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"GH020123.MP4", "GoPro", "0123", 2, time.Time{}},
+		{"GX010123.MP4", "GoPro", "0123", 1, time.Time{}},
+		{"GOPR0123.MP4", "GoPro", "0123", 0, time.Time{}},
+		{"GP010123.MP4", "GoPro", "0123", 1, time.Time{}},
+		{"IMG-20230326-WA0001.jpg", "WhatsApp", "20230326", 0, time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC)},
+		{"Screenshot_2023-03-26-09-59-12.png", "Screenshot", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"Screenshot_20230326-095912_Maps.png", "Screenshot", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"VID_20230326_095912.mp4", "Android", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"IMG_20230326_095912_HDR.jpg", "Android", "20230326095912", 0, time.Date(2023, 3, 26, 9, 59, 12, 0, time.UTC)},
+		{"holiday-42.jpg", "generic", "42", 0, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			name, ok := ParseFileName(filepath.Join("photos", tt.filename))
+			if !ok {
+				t.Fatalf("Not recognized")
+			}
+
+			if name.Scheme != tt.scheme || name.ID != tt.id || name.Chapter != tt.chapter || !name.Time.Equal(tt.time) {
+				t.Errorf("Expected %s %s %d %v, got %+v", tt.scheme, tt.id, tt.chapter, tt.time, name)
+			}
+		})
+	}
+
+	if _, ok := ParseFileName("photo.jpg"); ok {
+		t.Errorf("Expected a name without sequence ID")
+	}
+}
+
+func TestLoadFileNameSchemes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "filenames.json")
+	data := `[{"name": "Olympus", "pattern": "^P(?P<time>\\d{3})(?P<id>\\d{4})\\.\\w+$"}]`
+
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { FileNameSchemes = nil }()
+
+	if err := LoadFileNameSchemes(filename); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	name, ok := ParseFileName("P3260001.ORF")
+	if !ok || name.Scheme != "Olympus" || name.ID != "0001" {
+		t.Errorf("Unexpected name %+v", name)
+	}
+}
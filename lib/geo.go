@@ -2,34 +2,70 @@ package syncmediatrack
 
 import (
 	"fmt"
+	"math"
 	"strings"
+	"sync"
+	"time"
 
+	geo "github.com/codingsince1985/geo-golang"
 	"github.com/codingsince1985/geo-golang/openstreetmap"
 )
 
 var DefaultCountry string
 
+var (
+	// Minimum time between two requests to the geocoding service, openstreetmap allows one per second
+	GeocodeInterval = time.Second
+
+	geocoder     geo.Geocoder = openstreetmap.Geocoder()
+	geocodeMutex sync.Mutex
+	lastGeocode  time.Time
+	// Locations of the positions rounded to 0.001 degrees
+	geocodeCache = map[[2]float64]string{}
+)
+
+// ReverseLocation returns the name of the place of the position, the requests are made one at a
+// time and GeocodeInterval apart, and the positions already requested are not requested again
 func ReverseLocation(location Trkpt) (string, error) {
-	service := openstreetmap.Geocoder()
+	key := [2]float64{math.Round(location.Lat*1000) / 1000, math.Round(location.Lon*1000) / 1000}
+
+	geocodeMutex.Lock()
+	defer geocodeMutex.Unlock()
+
+	if name, ok := geocodeCache[key]; ok {
+		return name, nil
+	}
 
-	address, err := service.ReverseGeocode(location.Lat, location.Lon)
+	time.Sleep(time.Until(lastGeocode.Add(GeocodeInterval)))
+	address, err := geocoder.ReverseGeocode(location.Lat, location.Lon)
+	lastGeocode = time.Now()
 	if err != nil {
 		return "", err
 	}
 
+	name := ""
+	if address != nil {
+		name = addressName(address)
+	}
+	geocodeCache[key] = name
+
+	return name, nil
+}
+
+func addressName(address *geo.Address) string {
 	if len(address.City) < 9 && address.State != "" {
 		if DefaultCountry == address.CountryCode {
-			return fmt.Sprintf("%s %s", address.City, address.State), nil
+			return fmt.Sprintf("%s %s", address.City, address.State)
 		}
 
-		return fmt.Sprintf("%s %s %s", address.City, address.State, address.Country), nil
+		return fmt.Sprintf("%s %s %s", address.City, address.State, address.Country)
 	}
 
 	if DefaultCountry == address.CountryCode {
-		return address.City, nil
+		return address.City
 	}
 
-	return fmt.Sprintf("%s %s", address.City, address.Country), nil
+	return fmt.Sprintf("%s %s", address.City, address.Country)
 }
 
 func GeonameCleanup(input string) string {
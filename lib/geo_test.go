@@ -0,0 +1,71 @@
+package syncmediatrack
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	geo "github.com/codingsince1985/geo-golang"
+)
+
+type fakeGeocoder struct {
+	mutex    sync.Mutex
+	requests []time.Time
+}
+
+func (g *fakeGeocoder) Geocode(string) (*geo.Location, error) {
+	return nil, nil
+}
+
+func (g *fakeGeocoder) ReverseGeocode(lat, lng float64) (*geo.Address, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.requests = append(g.requests, time.Now())
+
+	return &geo.Address{City: "Andorra la Vella", State: "Andorra la Vella", Country: "Andorra", CountryCode: "ad"}, nil
+}
+
+func TestReverseLocation(t *testing.T) {
+	fake := &fakeGeocoder{}
+
+	defer func(g geo.Geocoder, interval time.Duration) {
+		geocoder, GeocodeInterval = g, interval
+		geocodeCache = map[[2]float64]string{}
+	}(geocoder, GeocodeInterval)
+
+	geocoder, GeocodeInterval = fake, 50*time.Millisecond
+	geocodeCache = map[[2]float64]string{}
+
+	positions := []Trkpt{
+		{Lat: 42.5063, Lon: 1.5218},
+		// the same place rounded to 0.001 degrees
+		{Lat: 42.50631, Lon: 1.52184},
+		{Lat: 42.5101, Lon: 1.5392},
+		{Lat: 42.4631, Lon: 1.4910},
+	}
+
+	var wg sync.WaitGroup
+	for _, position := range positions {
+		wg.Add(1)
+		go func(position Trkpt) {
+			defer wg.Done()
+
+			name, err := ReverseLocation(position)
+			if err != nil || name != "Andorra la Vella Andorra" {
+				t.Errorf("Expected Andorra la Vella Andorra, got %s %v", name, err)
+			}
+		}(position)
+	}
+	wg.Wait()
+
+	if len(fake.requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(fake.requests))
+	}
+
+	for i := 1; i < len(fake.requests); i++ {
+		if diff := fake.requests[i].Sub(fake.requests[i-1]); diff < GeocodeInterval {
+			t.Errorf("Expected the requests %s apart, got %s", GeocodeInterval, diff)
+		}
+	}
+}
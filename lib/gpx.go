@@ -8,7 +8,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/gabriel-vasile/mimetype"
 	"github.com/karrick/godirwalk"
 )
 
@@ -47,8 +46,8 @@ func ReadGPX(filename string, valid bool) error {
 
 	file, err := os.Open(filename)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		trackError++
+		return fmt.Errorf("Warning: GPX file could not be opened, error: %w", err)
 	}
 	defer file.Close()
 
@@ -115,7 +114,7 @@ func ReadGPX(filename string, valid bool) error {
 			// Print first and last time stamp
 			first := gpx.Trk.Trkseg.Trkpt[0]
 			last := gpx.Trk.Trkseg.Trkpt[len(gpx.Trk.Trkseg.Trkpt)-1]
-			fmt.Printf("First: %v Last: %v\n", GetTimeFromTrkpt(first), GetTimeFromTrkpt(last))
+			fmt.Printf("First: %v Last: %v\n", TrkptLocalTime(first), TrkptLocalTime(last))
 		}
 
 		DataGPX[filename] = gpx
@@ -134,12 +133,13 @@ func ReadGPXDir(trackDir string, valid bool) {
 				return nil // do not remove directory that was provided top-level directory
 			}
 
-			mtype, err := mimetype.DetectFile(path)
+			kind, err := ClassifyFile(path)
 			if err != nil {
-				log.Fatal(ColorRed(err))
+				Warning(fmt.Sprintf("%s can't be read, error: %s", path, err))
+				return nil
 			}
 
-			if !mtype.Is("application/gpx+xml") && !mtype.Is("text/xml") {
+			if kind != KindTrack {
 				return nil
 			}
 
@@ -176,17 +176,65 @@ func degrees2radians(degrees float64) float64 {
 	return degrees * math.Pi / 180
 }
 
+// GetTimeFromTrkpt returns the instant of the track point in UTC, zero if it has no valid time
 func GetTimeFromTrkpt(trkpt Trkpt) time.Time {
 	if len(trkpt.Time) == 0 {
 		return time.Time{}
 	}
 
-	t, err := time.Parse("2006-01-02T15:04:05Z", trkpt.Time)
+	t, err := ParseTrkptTime(trkpt.Time)
 	if err != nil {
 		return time.Time{}
 	}
 
-	return UpdateGPSDateTime(t, trkpt.Lat, trkpt.Lon)
+	return t
+}
+
+// TrkptLocalTime returns the time of the track point in the time zone of its position
+func TrkptLocalTime(trkpt Trkpt) time.Time {
+	return UpdateGPSDateTime(GetTimeFromTrkpt(trkpt), trkpt.Lat, trkpt.Lon)
+}
+
+// ParseTrkptTime reads a GPX time, in UTC or with a time zone offset and with optional fractional seconds
+func ParseTrkptTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
+
+// FormatTrkptTime writes the instant as a GPX time in UTC
+func FormatTrkptTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// GetClosestPosition returns the track point nearest to the position, only the points within the
+// window around the given time are checked. The distance is returned in meters
+func GetClosestPosition(lat float64, lon float64, around time.Time, window time.Duration, closestPoint *Trkpt) (float64, bool) {
+	closestDistance := -1.0
+
+	for _, gpx := range DataGPX {
+		for _, trkpt := range gpx.Trk.Trkseg.Trkpt {
+			trkptTime := GetTimeFromTrkpt(trkpt)
+			if trkptTime.IsZero() {
+				continue
+			}
+
+			if diff := around.Sub(trkptTime); diff > window || diff < -window {
+				continue
+			}
+
+			distance := distancePoints(lat, lon, trkpt.Lat, trkpt.Lon)
+			if closestDistance < 0 || distance < closestDistance {
+				*closestPoint = trkpt
+				closestDistance = distance
+			}
+		}
+	}
+
+	return closestDistance, closestDistance >= 0
 }
 
 func ReadTracks(track string, valid bool) {
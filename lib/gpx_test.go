@@ -1,7 +1,13 @@
 package syncmediatrack
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadGPX(t *testing.T) {
@@ -21,3 +27,78 @@ func TestReadGPX(t *testing.T) {
 		t.Errorf("Se esperaba un error al leer el archivo GPX inválido")
 	}
 }
+
+func TestReadGPXDirUnreadable(t *testing.T) {
+	defer func(data map[string]Gpx, messages io.Writer) {
+		DataGPX, Messages = data, messages
+		trackValid, trackError = 0, 0
+	}(DataGPX, Messages)
+
+	DataGPX = map[string]Gpx{}
+	var warnings bytes.Buffer
+	Messages = &warnings
+
+	dir := t.TempDir()
+
+	track, err := os.ReadFile("../testdata/tracks/2024_01_28_08_46_Sun.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := filepath.Join(dir, "valid.gpx")
+	if err := os.WriteFile(valid, track, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a track that can't be opened
+	unreadable := filepath.Join(dir, "unreadable.gpx")
+	if err := os.Symlink(filepath.Join(dir, "missing.gpx"), unreadable); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ReadGPX(unreadable, true); err == nil {
+		t.Errorf("Expected an error opening %s", unreadable)
+	}
+
+	ReadGPXDir(dir, true)
+
+	if _, ok := DataGPX[valid]; !ok || len(DataGPX) != 1 {
+		t.Errorf("Expected only %s to be read, got %d track(s)", valid, len(DataGPX))
+	}
+
+	if !strings.Contains(warnings.String(), "could not be opened") {
+		t.Errorf("Expected a warning for %s, got %q", unreadable, warnings.String())
+	}
+}
+
+func TestParseTrkptTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"2024-01-28T07:46:21Z", "2024-01-28T07:46:21Z"},
+		{"2024-01-28T07:46:21.500Z", "2024-01-28T07:46:21.5Z"},
+		{"2024-01-28T08:46:21+01:00", "2024-01-28T07:46:21Z"},
+		{"2024-01-27T23:46:21-08:00", "2024-01-28T07:46:21Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := ParseTrkptTime(tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if result.Format(time.RFC3339Nano) != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result.Format(time.RFC3339Nano))
+			}
+
+			if FormatTrkptTime(result) != tt.expected[:19]+"Z" {
+				t.Errorf("Expected %sZ, got %s", tt.expected[:19], FormatTrkptTime(result))
+			}
+		})
+	}
+
+	if _, err := ParseTrkptTime("2024-01-28 07:46:21"); err == nil {
+		t.Errorf("Expected an error without time zone")
+	}
+}
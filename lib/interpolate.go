@@ -0,0 +1,100 @@
+package syncmediatrack
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GeoMedia is a media with GPS position, the medias of a phone are a track for the other cameras
+type GeoMedia struct {
+	Path string
+	Time time.Time
+	GPS  Trkpt
+	// Naive is true when Time is the wall clock of the camera in UTC, it is read in the time zone
+	// of the position
+	Naive bool
+}
+
+// Instant returns the time of the media as an instant, false if it has no time zone and the time
+// zone of its position is unknown
+func (m GeoMedia) Instant() (time.Time, bool) {
+	if !m.Naive {
+		return m.Time, true
+	}
+
+	loc := zoneAt(m.GPS.Lat, m.GPS.Lon)
+	if loc == nil {
+		return m.Time, false
+	}
+
+	return wallTime(m.Time, loc), true
+}
+
+// InterpolatePosition returns the position at the given time from the geotagged medias taken
+// before and after it within the tolerance, weighted by time. When only one of them is within the
+// tolerance its position is copied. The medias are compared by their instant, the medias without
+// time zone whose position has no known time zone are ignored, and the interpolation is refused
+// when the two medias are more than maxDistance meters apart
+func InterpolatePosition(medias []GeoMedia, t time.Time, tolerance time.Duration, maxDistance float64) (Trkpt, []GeoMedia, error) {
+	medias = resolveGeoMedias(medias)
+
+	// first media after the time
+	i := sort.Search(len(medias), func(i int) bool {
+		return medias[i].Time.After(t)
+	})
+
+	var before, after *GeoMedia
+	if i > 0 && t.Sub(medias[i-1].Time) <= tolerance {
+		before = &medias[i-1]
+	}
+	if i < len(medias) && medias[i].Time.Sub(t) <= tolerance {
+		after = &medias[i]
+	}
+
+	switch {
+	case before == nil && after == nil:
+		return Trkpt{}, nil, fmt.Errorf("there is no geotagged media within %s", tolerance)
+	case after == nil:
+		return before.GPS, []GeoMedia{*before}, nil
+	case before == nil:
+		return after.GPS, []GeoMedia{*after}, nil
+	}
+
+	distance := distancePoints(before.GPS.Lat, before.GPS.Lon, after.GPS.Lat, after.GPS.Lon)
+	if distance > maxDistance {
+		return Trkpt{}, nil, fmt.Errorf("the geotagged medias before and after are %.0f m apart", distance)
+	}
+
+	fraction := 0.0
+	if gap := after.Time.Sub(before.Time); gap > 0 {
+		fraction = float64(t.Sub(before.Time)) / float64(gap)
+	}
+
+	position := Trkpt{
+		Lat: before.GPS.Lat + (after.GPS.Lat-before.GPS.Lat)*fraction,
+		Lon: before.GPS.Lon + (after.GPS.Lon-before.GPS.Lon)*fraction,
+		Ele: before.GPS.Ele + (after.GPS.Ele-before.GPS.Ele)*fraction,
+	}
+
+	return position, []GeoMedia{*before, *after}, nil
+}
+
+// resolveGeoMedias returns a copy of the medias with their instant sorted by time, without the
+// medias whose instant is unknown
+func resolveGeoMedias(medias []GeoMedia) []GeoMedia {
+	resolved := make([]GeoMedia, 0, len(medias))
+	for _, media := range medias {
+		t, ok := media.Instant()
+		if !ok {
+			continue
+		}
+
+		media.Time, media.Naive = t, false
+		resolved = append(resolved, media)
+	}
+
+	SortGeoMedias(resolved)
+
+	return resolved
+}
@@ -0,0 +1,73 @@
+package syncmediatrack
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInterpolatePosition(t *testing.T) {
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+
+	medias := []GeoMedia{
+		{Path: "PXL_1.jpg", Time: start, GPS: Trkpt{Lat: 40.0000, Lon: -1.0000, Ele: 1000}},
+		{Path: "PXL_2.jpg", Time: start.Add(2 * time.Minute), GPS: Trkpt{Lat: 40.0020, Lon: -1.0020, Ele: 1040}},
+		{Path: "PXL_3.jpg", Time: start.Add(10 * time.Minute), GPS: Trkpt{Lat: 40.0030, Lon: -1.0030, Ele: 1050}},
+		{Path: "PXL_4.jpg", Time: start.Add(12 * time.Minute), GPS: Trkpt{Lat: 40.1000, Lon: -1.0030, Ele: 1050}},
+	}
+
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected Trkpt
+		used     int
+		err      bool
+	}{
+		{"interpolated", start.Add(30 * time.Second), Trkpt{Lat: 40.0005, Lon: -1.0005, Ele: 1010}, 2, false},
+		{"same time", start.Add(2 * time.Minute), Trkpt{Lat: 40.0020, Lon: -1.0020, Ele: 1040}, 1, false},
+		{"only before", start.Add(4 * time.Minute), Trkpt{Lat: 40.0020, Lon: -1.0020, Ele: 1040}, 1, false},
+		{"only after", start.Add(-time.Minute), Trkpt{Lat: 40.0000, Lon: -1.0000, Ele: 1000}, 1, false},
+		{"too far in time", start.Add(6 * time.Minute), Trkpt{}, 0, true},
+		{"too far apart", start.Add(11 * time.Minute), Trkpt{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position, used, err := InterpolatePosition(medias, tt.time, 3*time.Minute, 500)
+			if (err != nil) != tt.err {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if len(used) != tt.used {
+				t.Errorf("Expected %d medias, got %d", tt.used, len(used))
+			}
+
+			if math.Abs(position.Lat-tt.expected.Lat) > 1e-9 || math.Abs(position.Lon-tt.expected.Lon) > 1e-9 || math.Abs(position.Ele-tt.expected.Ele) > 1e-9 {
+				t.Errorf("Expected %+v, got %+v", tt.expected, position)
+			}
+		})
+	}
+}
+
+func TestInterpolatePositionNaive(t *testing.T) {
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+
+	// the first media shows the local time of Madrid (UTC+1) without time zone
+	medias := []GeoMedia{
+		{Path: "DSC_1.jpg", Time: start.Add(time.Hour), GPS: Trkpt{Lat: 40.0000, Lon: -1.0000, Ele: 1000}, Naive: true},
+		{Path: "PXL_2.jpg", Time: start.Add(2 * time.Minute), GPS: Trkpt{Lat: 40.0020, Lon: -1.0020, Ele: 1040}},
+	}
+
+	position, used, err := InterpolatePosition(medias, start.Add(30*time.Second), 3*time.Minute, 500)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if len(used) != 2 || !used[0].Time.Equal(start) {
+		t.Errorf("Expected DSC_1.jpg at %s, got %+v", start, used)
+	}
+
+	if math.Abs(position.Lat-40.0005) > 1e-9 {
+		t.Errorf("Expected 40.0005, got %v", position.Lat)
+	}
+}
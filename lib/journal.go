@@ -0,0 +1,295 @@
+package syncmediatrack
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/barasher/go-exiftool"
+)
+
+// JournalEntry records a change of a file: the previous values of the tags, nil when the tag
+// didn't exist, and the checksum and modification time of the file before and after the change
+type JournalEntry struct {
+	File string             `json:"file"`
+	Time time.Time          `json:"time"`
+	Tags map[string]*string `json:"tags,omitempty"`
+	// Created is true when the file didn't exist, as a new sidecar
+	Created     bool      `json:"created,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Mtime       time.Time `json:"mtime,omitempty"`
+	NewChecksum string    `json:"newChecksum"`
+}
+
+var (
+	// JournalFile is the journal of the changes of the session, by default a new file in the
+	// journal directory is created with the first change
+	JournalFile string
+
+	journal      *os.File
+	journalMutex sync.Mutex
+)
+
+// JournalDir returns the directory of the journals
+func JournalDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "SyncMediaTrack", "journal"), nil
+}
+
+// openJournal creates the journal of the session the first time
+func openJournal() error {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	if journal != nil {
+		return nil
+	}
+
+	if JournalFile == "" {
+		dir, err := JournalDir()
+		if err != nil {
+			return fmt.Errorf("the journal directory could not be found: %w", err)
+		}
+		JournalFile = filepath.Join(dir, time.Now().Format("20060102-150405.000")+".jsonl")
+	}
+
+	err := os.MkdirAll(filepath.Dir(JournalFile), 0o755)
+	if err != nil {
+		return err
+	}
+
+	journal, err = os.OpenFile(JournalFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+
+	return err
+}
+
+// CloseJournal closes the journal of the session and returns its name, empty if there was no change
+func CloseJournal() (string, error) {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	if journal == nil {
+		return "", nil
+	}
+
+	err := journal.Close()
+	journal = nil
+
+	return JournalFile, err
+}
+
+func writeJournalEntry(entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	if journal == nil {
+		return errors.New("the journal is closed")
+	}
+
+	_, err = journal.Write(append(data, '\n'))
+
+	return err
+}
+
+// recordChange calls change and records in the journal the previous values of the tags and the
+// checksum and modification time of the file, the file is not changed if the journal can't be
+// written
+func recordChange(filename string, tags []string, change func() error) error {
+	if err := openJournal(); err != nil {
+		return fmt.Errorf("the journal could not be created, %s has not been changed: %w", filename, err)
+	}
+
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+
+	entry := JournalEntry{File: path, Time: time.Now()}
+
+	f, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		entry.Created = true
+	case err != nil:
+		return err
+	default:
+		entry.Mtime = f.ModTime()
+
+		entry.Checksum, err = fileChecksum(path)
+		if err != nil {
+			return err
+		}
+
+		entry.Tags, err = readTagValues(path, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	changeErr := change()
+
+	entry.NewChecksum, err = fileChecksum(path)
+	if err != nil && !entry.Created {
+		return errors.Join(changeErr, err)
+	}
+	if f, err := os.Stat(path); err == nil && entry.NewChecksum == entry.Checksum && f.ModTime().Equal(entry.Mtime) {
+		// nothing changed
+		return changeErr
+	}
+
+	return errors.Join(changeErr, writeJournalEntry(entry))
+}
+
+// readTagValues returns the values of the tags without print conversion, nil when the file
+// doesn't have the tag
+func readTagValues(filename string, tags []string) (map[string]*string, error) {
+	values := map[string]*string{}
+	if len(tags) == 0 {
+		return values, nil
+	}
+
+	et, err := getRawExiftool()
+	if err != nil {
+		return nil, err
+	}
+
+	metas := et.ExtractMetadata(filename)
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("no metadata found %s", filename)
+	}
+	if metas[0].Err != nil {
+		return nil, metas[0].Err
+	}
+
+	for _, tag := range tags {
+		// the values are read without the group of the tag
+		name := tag[strings.LastIndex(tag, ":")+1:]
+
+		value, err := metas[0].GetString(name)
+		if err != nil {
+			values[tag] = nil
+			continue
+		}
+		values[tag] = &value
+	}
+
+	return values, nil
+}
+
+func fileChecksum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadJournal returns the changes recorded in the journal
+func ReadJournal(filename string) ([]JournalEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("journal %s could not be processed, line %d: %w", filename, line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// SetFileTime changes the modification time of the file and records it in the journal
+func SetFileTime(filename string, t time.Time) error {
+	return recordChange(filename, nil, func() error {
+		return os.Chtimes(filename, t, t)
+	})
+}
+
+// UndoChange restores the file as it was before the change, the file must not have been modified
+// after the change unless force is true. It returns true when the file is restored byte by byte
+func UndoChange(entry JournalEntry, force bool) (bool, error) {
+	checksum, err := fileChecksum(entry.File)
+	if err != nil {
+		return false, err
+	}
+	if checksum != entry.NewChecksum && !force {
+		return false, fmt.Errorf("%s has been modified after the change", entry.File)
+	}
+
+	if entry.Created {
+		return true, os.Remove(entry.File)
+	}
+
+	if len(entry.Tags) > 0 {
+		et, err := getRawExiftool()
+		if err != nil {
+			return false, err
+		}
+
+		fileInfo := exiftool.EmptyFileMetadata()
+		fileInfo.File = entry.File
+		for tag, value := range entry.Tags {
+			if value == nil {
+				fileInfo.Clear(tag)
+			} else {
+				fileInfo.SetString(tag, *value)
+			}
+		}
+
+		fileInfos := []exiftool.FileMetadata{fileInfo}
+		et.WriteMetadata(fileInfos)
+		if fileInfos[0].Err != nil {
+			return false, fileInfos[0].Err
+		}
+	}
+
+	err = os.Chtimes(entry.File, entry.Mtime, entry.Mtime)
+	if err != nil {
+		return false, err
+	}
+
+	checksum, err = fileChecksum(entry.File)
+	if err != nil {
+		return false, err
+	}
+
+	return checksum == entry.Checksum, nil
+}
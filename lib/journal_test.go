@@ -0,0 +1,82 @@
+package syncmediatrack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalUndo(t *testing.T) {
+	dir := t.TempDir()
+
+	JournalFile = filepath.Join(dir, "journal.jsonl")
+	defer func() { JournalFile = "" }()
+
+	media := filepath.Join(dir, "IMG_0001.JPG")
+	if err := os.WriteFile(media, []byte("media"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 1, 28, 8, 46, 21, 0, time.UTC)
+	if err := os.Chtimes(media, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetFileTime(media, mtime.Add(time.Hour)); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	sidecar := filepath.Join(dir, "IMG_0001.xmp")
+	err := recordChange(sidecar, nil, func() error {
+		return os.WriteFile(sidecar, []byte(emptyXMP), 0o644)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// an unchanged file is not recorded
+	if err := SetFileTime(media, mtime.Add(time.Hour)); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	journal, err := CloseJournal()
+	if err != nil || journal != JournalFile {
+		t.Fatalf("Expected journal %s, got %s %v", JournalFile, journal, err)
+	}
+
+	entries, err := ReadJournal(journal)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %+v", entries)
+	}
+	if entries[0].File != media || !entries[0].Mtime.Equal(mtime) || entries[0].Created {
+		t.Errorf("Expected the change of the modification time of %s, got %+v", media, entries[0])
+	}
+	if entries[1].File != sidecar || !entries[1].Created {
+		t.Errorf("Expected the creation of %s, got %+v", sidecar, entries[1])
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		exact, err := UndoChange(entries[i], false)
+		if err != nil || !exact {
+			t.Errorf("Expected %s to be restored, got %v %v", entries[i].File, exact, err)
+		}
+	}
+
+	if _, err := os.Stat(sidecar); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", sidecar)
+	}
+	if f, err := os.Stat(media); err != nil || !f.ModTime().Equal(mtime) {
+		t.Errorf("Expected the modification time %s, got %v", mtime, f.ModTime())
+	}
+
+	// the file modified after the change is not restored
+	if err := os.WriteFile(media, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UndoChange(entries[0], false); err == nil {
+		t.Errorf("Expected an error restoring a modified file")
+	}
+}
@@ -1,25 +1,106 @@
 package syncmediatrack
 
 import (
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/gabriel-vasile/mimetype"
 )
 
-func FileIsMedia(filename string) bool {
+// FileKind is the type of content of a file
+type FileKind int
+
+const (
+	KindOther FileKind = iota
+	KindImage
+	KindVideo
+	KindAudio
+	KindTrack
+	KindSidecar
+)
+
+var (
+	// Kind of the files by extension, the other files are recognized by their content
+	fileExtensions = map[string]FileKind{
+		".jpg": KindImage, ".jpeg": KindImage, ".png": KindImage, ".gif": KindImage, ".bmp": KindImage,
+		".tif": KindImage, ".tiff": KindImage, ".webp": KindImage, ".heic": KindImage, ".heif": KindImage,
+		".avif": KindImage, ".insp": KindImage, ".thm": KindImage,
+		// RAW formats
+		".cr2": KindImage, ".cr3": KindImage, ".crw": KindImage, ".nef": KindImage, ".nrw": KindImage,
+		".arw": KindImage, ".srf": KindImage, ".sr2": KindImage, ".dng": KindImage, ".raf": KindImage,
+		".orf": KindImage, ".rw2": KindImage, ".pef": KindImage, ".srw": KindImage, ".x3f": KindImage,
+		".3fr": KindImage, ".iiq": KindImage, ".erf": KindImage, ".mrw": KindImage, ".rwl": KindImage,
+		".mp4": KindVideo, ".mov": KindVideo, ".m4v": KindVideo, ".avi": KindVideo, ".mkv": KindVideo,
+		".mts": KindVideo, ".m2ts": KindVideo, ".3gp": KindVideo, ".3g2": KindVideo, ".wmv": KindVideo,
+		".mpg": KindVideo, ".mpeg": KindVideo, ".webm": KindVideo, ".insv": KindVideo, ".lrv": KindVideo,
+		".mp3": KindAudio, ".m4a": KindAudio, ".wav": KindAudio, ".aac": KindAudio, ".ogg": KindAudio,
+		".opus": KindAudio, ".amr": KindAudio, ".flac": KindAudio,
+		".gpx": KindTrack,
+		".xmp": KindSidecar,
+	}
+
+	// Kind of the files recognized by their content, they are read only once
+	sniffedKinds = map[string]FileKind{}
+	sniffedMutex sync.Mutex
+)
+
+// ClassifyFile returns the kind of the file from its extension or from its content when the
+// extension is unknown, the error is returned when the file can't be read
+func ClassifyFile(filename string) (FileKind, error) {
+	if kind, ok := fileExtensions[strings.ToLower(filepath.Ext(filename))]; ok {
+		return kind, nil
+	}
+
+	sniffedMutex.Lock()
+	kind, ok := sniffedKinds[filename]
+	sniffedMutex.Unlock()
+	if ok {
+		return kind, nil
+	}
+
 	mtype, err := mimetype.DetectFile(filename)
 	if err != nil {
-		return false
+		return KindOther, err
 	}
 
-	return strings.Contains(mtype.String(), "video/") || strings.Contains(mtype.String(), "image/") || strings.Contains(mtype.String(), "audio/")
+	kind = mimeKind(mtype)
+
+	sniffedMutex.Lock()
+	sniffedKinds[filename] = kind
+	sniffedMutex.Unlock()
+
+	return kind, nil
 }
 
-func FileIsVideo(filename string) bool {
-	mtype, err := mimetype.DetectFile(filename)
-	if err != nil {
-		return false
+func mimeKind(mtype *mimetype.MIME) FileKind {
+	switch {
+	case strings.HasPrefix(mtype.String(), "video/"):
+		return KindVideo
+	case strings.HasPrefix(mtype.String(), "image/"):
+		return KindImage
+	case strings.HasPrefix(mtype.String(), "audio/"):
+		return KindAudio
+	case mtype.Is("application/gpx+xml") || mtype.Is("text/xml"):
+		return KindTrack
 	}
 
-	return strings.Contains(mtype.String(), "video/")
+	return KindOther
+}
+
+// IsMedia checks if the kind is an image, a video or an audio
+func (k FileKind) IsMedia() bool {
+	return k == KindImage || k == KindVideo || k == KindAudio
+}
+
+func FileIsMedia(filename string) bool {
+	kind, err := ClassifyFile(filename)
+
+	return err == nil && kind.IsMedia()
+}
+
+func FileIsVideo(filename string) bool {
+	kind, err := ClassifyFile(filename)
+
+	return err == nil && kind == KindVideo
 }
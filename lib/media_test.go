@@ -0,0 +1,36 @@
+package syncmediatrack
+
+import (
+	"testing"
+)
+
+func TestClassifyFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected FileKind
+		err      bool
+	}{
+		// known extensions are not read
+		{"missing/IMG_0001.CR3", KindImage, false},
+		{"missing/DSC_0001.nef", KindImage, false},
+		{"missing/IMG_0001.HEIC", KindImage, false},
+		{"missing/VID_20230326_095912_00_001.insv", KindVideo, false},
+		{"missing/GH010123.MP4", KindVideo, false},
+		{"missing/track.gpx", KindTrack, false},
+		// unknown extensions are recognized by their content
+		{"media_test.go", KindOther, false},
+		{"missing/file.unknown", KindOther, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			kind, err := ClassifyFile(tt.filename)
+			if (err != nil) != tt.err {
+				t.Fatalf("Unexpected error %v", err)
+			}
+			if kind != tt.expected {
+				t.Errorf("Expected kind %d, got %d", tt.expected, kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package syncmediatrack
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Name of the track with the positions of the medias taken out of the time of the tracks
+const MediaTrackName = "medias"
+
+// SortGeoMedias sorts the medias by time
+func SortGeoMedias(medias []GeoMedia) {
+	sort.Slice(medias, func(i, j int) bool {
+		if !medias[i].Time.Equal(medias[j].Time) {
+			return medias[i].Time.Before(medias[j].Time)
+		}
+		return medias[i].Path < medias[j].Path
+	})
+}
+
+// SplitSegments splits the medias sorted by time in segments, a new segment starts when the
+// time from the previous media is greater than gap
+func SplitSegments(medias []GeoMedia, gap time.Duration) [][]GeoMedia {
+	var segments [][]GeoMedia
+
+	for i, media := range medias {
+		if i == 0 || media.Time.Sub(medias[i-1].Time) > gap {
+			segments = append(segments, nil)
+		}
+		segments[len(segments)-1] = append(segments[len(segments)-1], media)
+	}
+
+	return segments
+}
+
+// FillTrackGaps adds the positions of the geotagged medias to the tracks when there is no track
+// point closer in time than gap, and returns the medias that have been added with their instant.
+// The position is inserted in the track that covers its time or in the MediaTrackName track, the
+// medias without time zone whose position has no known time zone are not added
+func FillTrackGaps(medias []GeoMedia, gap time.Duration) []GeoMedia {
+	var added []GeoMedia
+
+	for _, media := range medias {
+		t, ok := media.Instant()
+		if !ok {
+			continue
+		}
+
+		var closest Trkpt
+		if diff, ok := closestTrackTime(t, &closest); ok && diff <= gap {
+			continue
+		}
+
+		trkpt := media.GPS
+		trkpt.Time = FormatTrkptTime(t)
+		insertTrkpt(trkpt, t)
+
+		media.Time, media.Naive = t, false
+		added = append(added, media)
+	}
+
+	return added
+}
+
+// insertTrkpt adds the point to the track that covers its time keeping the order of the points
+func insertTrkpt(trkpt Trkpt, t time.Time) {
+	filename := MediaTrackName
+	for name, gpx := range DataGPX {
+		points := gpx.Trk.Trkseg.Trkpt
+		if len(points) == 0 {
+			continue
+		}
+
+		if isBetween(t, GetTimeFromTrkpt(points[0]), GetTimeFromTrkpt(points[len(points)-1])) {
+			filename = name
+			break
+		}
+	}
+
+	gpx := DataGPX[filename]
+	points := gpx.Trk.Trkseg.Trkpt
+
+	i := sort.Search(len(points), func(i int) bool {
+		return GetTimeFromTrkpt(points[i]).After(t)
+	})
+
+	gpx.Trk.Trkseg.Trkpt = slices.Insert(points, i, trkpt)
+	DataGPX[filename] = gpx
+}
@@ -0,0 +1,130 @@
+package syncmediatrack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitSegments(t *testing.T) {
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+
+	medias := []GeoMedia{
+		{Path: "GX010002.MP4", Time: start.Add(2 * time.Hour)},
+		{Path: "PXL_1.jpg", Time: start},
+		{Path: "PXL_3.jpg", Time: start.Add(20 * time.Minute)},
+		{Path: "PXL_2.jpg", Time: start.Add(10 * time.Minute)},
+		{Path: "PXL_4.jpg", Time: start.Add(3 * time.Hour)},
+	}
+
+	SortGeoMedias(medias)
+	segments := SplitSegments(medias, 30*time.Minute)
+
+	expected := [][]string{{"PXL_1.jpg", "PXL_2.jpg", "PXL_3.jpg"}, {"GX010002.MP4"}, {"PXL_4.jpg"}}
+	if len(segments) != len(expected) {
+		t.Fatalf("Expected %d segments, got %d", len(expected), len(segments))
+	}
+
+	for i, segment := range segments {
+		if len(segment) != len(expected[i]) {
+			t.Fatalf("Segment %d: expected %v, got %v", i, expected[i], segment)
+		}
+		for j, media := range segment {
+			if media.Path != expected[i][j] {
+				t.Errorf("Segment %d: expected %s, got %s", i, expected[i][j], media.Path)
+			}
+		}
+	}
+
+	if segments := SplitSegments(nil, time.Hour); len(segments) != 0 {
+		t.Errorf("Expected no segments, got %v", segments)
+	}
+}
+
+func TestFillTrackGaps(t *testing.T) {
+	defer func(data map[string]Gpx) { DataGPX = data }(DataGPX)
+
+	// a track from 10:00 to 12:00 with a gap between 10:30 and 11:30
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	track := func() map[string]Gpx {
+		var gpx Gpx
+		for i := 0; i <= 120; i++ {
+			if i > 30 && i < 90 {
+				continue
+			}
+			gpx.Trk.Trkseg.Trkpt = append(gpx.Trk.Trkseg.Trkpt, Trkpt{Lat: 40, Lon: -1, Time: FormatTrkptTime(start.Add(time.Duration(i) * time.Minute))})
+		}
+		return map[string]Gpx{"track.gpx": gpx}
+	}
+	DataGPX = track()
+
+	medias := []GeoMedia{
+		{Path: "PXL_1.jpg", Time: start.Add(15 * time.Minute), GPS: Trkpt{Lat: 41, Lon: -2}},
+		{Path: "PXL_2.jpg", Time: start.Add(50 * time.Minute), GPS: Trkpt{Lat: 42, Lon: -3}},
+		{Path: "PXL_3.jpg", Time: start.Add(70 * time.Minute), GPS: Trkpt{Lat: 43, Lon: -4}},
+		{Path: "PXL_4.jpg", Time: start.Add(5 * time.Hour), GPS: Trkpt{Lat: 44, Lon: -5}},
+	}
+
+	added := FillTrackGaps(medias, 5*time.Minute)
+	if len(added) != 3 {
+		t.Fatalf("Expected 3 positions added, got %v", added)
+	}
+
+	var closest Trkpt
+	if !GetClosesGPS(start.Add(51*time.Minute), &closest) || closest.Lat != 42 {
+		t.Errorf("Expected the position of PXL_2.jpg, got %+v", closest)
+	}
+
+	points := DataGPX["track.gpx"].Trk.Trkseg.Trkpt
+	for i := 1; i < len(points); i++ {
+		if GetTimeFromTrkpt(points[i]).Before(GetTimeFromTrkpt(points[i-1])) {
+			t.Fatalf("Points out of order at %d", i)
+		}
+	}
+
+	if len(DataGPX[MediaTrackName].Trk.Trkseg.Trkpt) != 1 {
+		t.Errorf("Expected the position out of the track in %s", MediaTrackName)
+	}
+
+	// without the medias the gap is not bridged
+	DataGPX = track()
+	if GetClosesGPS(start.Add(60*time.Minute), &closest) {
+		t.Errorf("Expected no position in the gap, got %+v", closest)
+	}
+}
+
+func TestFillTrackGapsNaive(t *testing.T) {
+	defer func(data map[string]Gpx) { DataGPX = data }(DataGPX)
+
+	// a track in Madrid (UTC+1) from 10:00 to 12:00 UTC with a gap between 10:30 and 11:30
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	var gpx Gpx
+	for i := 0; i <= 120; i++ {
+		if i > 30 && i < 90 {
+			continue
+		}
+		gpx.Trk.Trkseg.Trkpt = append(gpx.Trk.Trkseg.Trkpt, Trkpt{Lat: 40.4, Lon: -3.7, Time: FormatTrkptTime(start.Add(time.Duration(i) * time.Minute))})
+	}
+	DataGPX = map[string]Gpx{"track.gpx": gpx}
+
+	// the camera shows 11:50 in Madrid, 10:50 UTC, in the gap of the track. Read as UTC it would
+	// be near the points at 11:50
+	naive := time.Date(2024, 1, 28, 11, 50, 0, 0, time.UTC)
+	medias := []GeoMedia{
+		{Path: "IMG_0001.JPG", Time: naive, GPS: Trkpt{Lat: 40.41, Lon: -3.71}, Naive: true},
+	}
+
+	added := FillTrackGaps(medias, 5*time.Minute)
+	if len(added) != 1 {
+		t.Fatalf("Expected 1 position added, got %v", added)
+	}
+
+	expected := time.Date(2024, 1, 28, 10, 50, 0, 0, time.UTC)
+	if !added[0].Time.Equal(expected) || added[0].Naive {
+		t.Errorf("Expected %v, got %v", expected, added[0].Time)
+	}
+
+	var closest Trkpt
+	if !GetClosesGPS(expected, &closest) || closest.Lat != 40.41 || closest.Time != "2024-01-28T10:50:00Z" {
+		t.Errorf("Expected the position of IMG_0001.JPG at 10:50 UTC, got %+v", closest)
+	}
+}
@@ -0,0 +1,354 @@
+package syncmediatrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CameraProfile describes the time zone and the clock error of a camera, it is selected by the
+// make, model and serial number of the camera and/or the directory of the medias
+type CameraProfile struct {
+	Name     string `json:"name,omitempty"`
+	Make     string `json:"make,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+	Dir      string `json:"dir,omitempty"`
+	TimeZone string `json:"timezone,omitempty"`
+	// Offset is the error of the camera clock at the reference time, positive when the camera was ahead
+	Offset Duration `json:"offset,omitempty"`
+	// Drift is the error gained by the camera clock every day
+	Drift     Duration   `json:"drift,omitempty"`
+	Reference *time.Time `json:"reference,omitempty"`
+
+	location *time.Location
+}
+
+// Duration is a time.Duration stored as text in the profiles file
+type Duration time.Duration
+
+var CameraProfiles []CameraProfile
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", s)
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+// ParseTimeZone returns the location of an IANA time zone name, UTC or Local
+func ParseTimeZone(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "utc") || strings.EqualFold(name, "gmt") {
+		return time.UTC, nil
+	}
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %s", name)
+	}
+
+	return loc, nil
+}
+
+// ParseCameraProfile reads a profile with the format "key=value,..." the keys are name, make,
+// model, serial, dir, tz, offset, drift and reference, all of them are optional
+func ParseCameraProfile(spec string) (CameraProfile, error) {
+	var profile CameraProfile
+
+	for _, field := range strings.Split(spec, ",") {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			return profile, fmt.Errorf("invalid camera profile %s", field)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "name":
+			profile.Name = value
+		case "make":
+			profile.Make = value
+		case "model":
+			profile.Model = value
+		case "serial":
+			profile.Serial = value
+		case "dir":
+			profile.Dir = value
+		case "tz":
+			profile.TimeZone = value
+		case "offset":
+			offset, err := time.ParseDuration(value)
+			if err != nil {
+				return profile, fmt.Errorf("invalid clock offset %s", value)
+			}
+			profile.Offset = Duration(offset)
+		case "drift":
+			drift, err := time.ParseDuration(value)
+			if err != nil {
+				return profile, fmt.Errorf("invalid clock drift %s", value)
+			}
+			profile.Drift = Duration(drift)
+		case "reference":
+			reference, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return profile, fmt.Errorf("invalid reference time %s", value)
+			}
+			profile.Reference = &reference
+		default:
+			return profile, fmt.Errorf("unknown camera profile setting %s", key)
+		}
+	}
+
+	return profile, profile.init()
+}
+
+// LoadCameraProfiles reads a JSON file with a list of camera profiles
+func LoadCameraProfiles(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var profiles []CameraProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("camera profiles %s could not be processed, error: %w", filename, err)
+	}
+
+	for i := range profiles {
+		if err := profiles[i].init(); err != nil {
+			return fmt.Errorf("camera profile %s: %w", profiles[i], err)
+		}
+	}
+
+	CameraProfiles = append(CameraProfiles, profiles...)
+
+	return nil
+}
+
+// SaveCameraProfile stores the profile in a JSON file, replacing the profile of the same camera
+func SaveCameraProfile(filename string, profile CameraProfile) error {
+	var profiles []CameraProfile
+
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		if err := json.Unmarshal(data, &profiles); err != nil {
+			return fmt.Errorf("camera profiles %s could not be processed, error: %w", filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	replaced := false
+	for i := range profiles {
+		if profiles[i].sameCamera(profile) {
+			profiles[i] = profile
+			replaced = true
+		}
+	}
+	if !replaced {
+		profiles = append(profiles, profile)
+	}
+
+	data, err = json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), 0o644) //nolint:gosec
+}
+
+func (p *CameraProfile) sameCamera(other CameraProfile) bool {
+	return strings.EqualFold(p.Make, other.Make) &&
+		strings.EqualFold(p.Model, other.Model) &&
+		strings.EqualFold(p.Serial, other.Serial) &&
+		p.Dir == other.Dir
+}
+
+// AddCameraProfile validates a profile and adds it to the list of profiles
+func AddCameraProfile(profile CameraProfile) error {
+	if err := profile.init(); err != nil {
+		return err
+	}
+
+	CameraProfiles = append(CameraProfiles, profile)
+
+	return nil
+}
+
+// ReplaceCameraProfile validates a profile and replaces the profiles of the same camera
+func ReplaceCameraProfile(profile CameraProfile) error {
+	if err := profile.init(); err != nil {
+		return err
+	}
+
+	profiles := CameraProfiles[:0]
+	for _, p := range CameraProfiles {
+		if !p.sameCamera(profile) {
+			profiles = append(profiles, p)
+		}
+	}
+
+	CameraProfiles = append(profiles, profile)
+
+	return nil
+}
+
+func (p *CameraProfile) init() error {
+	if p.TimeZone != "" {
+		loc, err := ParseTimeZone(p.TimeZone)
+		if err != nil {
+			return err
+		}
+		p.location = loc
+	}
+
+	if p.Drift != 0 && p.Reference == nil {
+		return fmt.Errorf("the clock drift needs a reference time")
+	}
+
+	return nil
+}
+
+func (p CameraProfile) String() string {
+	if p.Name != "" {
+		return p.Name
+	}
+
+	name := strings.TrimSpace(strings.Join([]string{p.Make, p.Model, p.Serial, p.Dir}, " "))
+	if name == "" {
+		return "default"
+	}
+
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// FindCameraProfile returns the most specific profile for the media, nil if there is none
+func FindCameraProfile(filename string, camera Camera) *CameraProfile {
+	var best *CameraProfile
+	bestScore := -1
+
+	for i := range CameraProfiles {
+		score, ok := CameraProfiles[i].match(filename, camera)
+		if ok && score > bestScore {
+			best = &CameraProfiles[i]
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+func (p *CameraProfile) match(filename string, camera Camera) (int, bool) {
+	score := 0
+
+	if p.Dir != "" {
+		if !inDir(filename, p.Dir) {
+			return 0, false
+		}
+		score++
+	}
+
+	fields := [][2]string{{p.Make, camera.Make}, {p.Model, camera.Model}, {p.Serial, camera.Serial}}
+	for _, field := range fields {
+		if field[0] == "" {
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(field[0]), strings.TrimSpace(field[1])) {
+			return 0, false
+		}
+		score++
+	}
+
+	return score, true
+}
+
+// ClockError returns the error of the camera clock at the time given by the camera
+func (p *CameraProfile) ClockError(t time.Time) time.Duration {
+	if p.Drift == 0 || p.Reference == nil {
+		return time.Duration(p.Offset)
+	}
+
+	days := t.Sub(*p.Reference).Hours() / 24
+
+	return time.Duration(p.Offset) + time.Duration(float64(p.Drift)*days)
+}
+
+// CorrectCameraTime adjusts a date stored by the camera, a time without time zone is
+// interpreted in the time zone of the camera
+func (p *CameraProfile) CorrectCameraTime(t time.Time, naive bool) time.Time {
+	if naive && p.location != nil {
+		t = wallTime(t, p.location)
+	}
+
+	return t.Add(-p.ClockError(t))
+}
+
+// CorrectFileTime adjusts the modification time of the file, written by the camera with its
+// clock in the time zone of the camera and read by the system in the local time zone
+func (p *CameraProfile) CorrectFileTime(t time.Time) time.Time {
+	if p.location != nil {
+		t = wallTime(t.In(time.Local), p.location)
+	}
+
+	return t.Add(-p.ClockError(t))
+}
+
+// ParseShownTime reads the time shown by the GPS device in the time zone of the photo, when only
+// the time is given the date is taken from the photo
+func ParseShownTime(shown string, photoTime time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", shown, photoTime.Location())
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.ParseInLocation("15:04:05", shown, photoTime.Location())
+	if err != nil {
+		return t, fmt.Errorf("invalid time shown %s", shown)
+	}
+
+	t = time.Date(photoTime.Year(), photoTime.Month(), photoTime.Day(), t.Hour(), t.Minute(), t.Second(), 0, photoTime.Location())
+
+	// the clocks can be on different sides of midnight
+	if diff := t.Sub(photoTime); diff > 12*time.Hour {
+		t = t.AddDate(0, 0, -1)
+	} else if diff < -12*time.Hour {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+func inDir(filename string, dir string) bool {
+	absFile, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
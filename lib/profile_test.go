@@ -0,0 +1,155 @@
+package syncmediatrack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCameraProfile(t *testing.T) {
+	profile, err := ParseCameraProfile("dir=photos/Canon,model=Canon EOS R5,tz=Europe/Madrid,offset=3m20s")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if profile.Dir != "photos/Canon" || profile.Model != "Canon EOS R5" || profile.Offset != Duration(200*time.Second) {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+
+	// The camera was 3m20s ahead in the time zone of Madrid (UTC+1)
+	naive := time.Date(2024, 1, 28, 9, 0, 0, 0, time.UTC)
+	expected := time.Date(2024, 1, 28, 7, 56, 40, 0, time.UTC)
+
+	result := profile.CorrectCameraTime(naive, true)
+	if !result.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	// An absolute instant only gets the offset
+	result = profile.CorrectCameraTime(expected, false)
+	if !result.Equal(expected.Add(-200 * time.Second)) {
+		t.Errorf("Expected %v, got %v", expected.Add(-200*time.Second), result)
+	}
+
+	_, err = ParseCameraProfile("tz=Nowhere/City")
+	if err == nil {
+		t.Errorf("Expected an error with an invalid time zone")
+	}
+
+	_, err = ParseCameraProfile("drift=2s")
+	if err == nil {
+		t.Errorf("Expected an error with a drift without reference time")
+	}
+}
+
+func TestCameraProfileDrift(t *testing.T) {
+	profile, err := ParseCameraProfile("offset=10s,drift=2s,reference=2024-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	tests := []struct {
+		time     time.Time
+		expected time.Duration
+	}{
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 10 * time.Second},
+		{time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC), 30 * time.Second},
+		{time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC), 9 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if result := profile.ClockError(tt.time); result != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.time, tt.expected, result)
+		}
+	}
+}
+
+func TestFindCameraProfile(t *testing.T) {
+	CameraProfiles = []CameraProfile{
+		{Offset: Duration(time.Second)},
+		{Model: "X100V", Offset: Duration(2 * time.Second)},
+		{Dir: "photos/Fuji", Model: "X100V", Offset: Duration(3 * time.Second)},
+		{Make: "FUJIFILM", Model: "X100V", Serial: "1234", Offset: Duration(4 * time.Second)},
+	}
+	defer func() { CameraProfiles = nil }()
+
+	tests := []struct {
+		filename string
+		camera   Camera
+		expected Duration
+	}{
+		{"photos/Canon/IMG_0001.JPG", Camera{Make: "Canon", Model: "Canon EOS R5"}, Duration(time.Second)},
+		{"photos/Canon/DSCF0001.JPG", Camera{Make: "FUJIFILM", Model: "X100V"}, Duration(2 * time.Second)},
+		{"photos/Fuji/DSCF0001.JPG", Camera{Make: "FUJIFILM", Model: "X100V"}, Duration(3 * time.Second)},
+		{"photos/Fujifilm/DSCF0001.JPG", Camera{Make: "FUJIFILM", Model: "X100V"}, Duration(2 * time.Second)},
+		{"photos/Fuji/DSCF0001.JPG", Camera{Make: "FUJIFILM", Model: "X100V", Serial: "1234"}, Duration(4 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		profile := FindCameraProfile(tt.filename, tt.camera)
+		if profile == nil || profile.Offset != tt.expected {
+			t.Errorf("%s: expected offset %v, got %+v", tt.filename, tt.expected, profile)
+		}
+	}
+}
+
+func TestLoadCameraProfiles(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "profiles.json")
+	data := `[{"name": "Canon", "make": "Canon", "timezone": "UTC", "offset": "-1m30s", "drift": "1s", "reference": "2024-01-01T00:00:00Z"}]`
+
+	if err := os.WriteFile(filename, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { CameraProfiles = nil }()
+
+	if err := LoadCameraProfiles(filename); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if len(CameraProfiles) != 1 || CameraProfiles[0].String() != "Canon" || CameraProfiles[0].Offset != Duration(-90*time.Second) {
+		t.Errorf("Unexpected profiles %+v", CameraProfiles)
+	}
+}
+
+func TestParseShownTime(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	photo := time.Date(2024, 1, 28, 12, 0, 0, 0, madrid)
+	evening := time.Date(2024, 1, 28, 23, 58, 0, 0, madrid)
+	morning := time.Date(2024, 1, 28, 0, 1, 0, 0, madrid)
+
+	tests := []struct {
+		name     string
+		shown    string
+		photo    time.Time
+		expected time.Time
+	}{
+		{"same day", "12:03:10", photo, time.Date(2024, 1, 28, 12, 3, 10, 0, madrid)},
+		{"full date", "2024-01-27 11:59:00", photo, time.Date(2024, 1, 27, 11, 59, 0, 0, madrid)},
+		{"next day", "00:02:00", evening, time.Date(2024, 1, 29, 0, 2, 0, 0, madrid)},
+		{"previous day", "23:59:30", morning, time.Date(2024, 1, 27, 23, 59, 30, 0, madrid)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseShownTime(tt.shown, tt.photo)
+			if err != nil {
+				t.Fatalf("Unexpected error %v", err)
+			}
+
+			if !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	for _, shown := range []string{"", "12:03", "25:00:00", "2024-01-28T12:03:10Z", "noon"} {
+		if _, err := ParseShownTime(shown, photo); err == nil {
+			t.Errorf("Expected an error with %q", shown)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package syncmediatrack
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SequenceItem is a media ordered by the number in its filename
+type SequenceItem struct {
+	Path string
+	// Sequence groups the medias numbered by the same counter: the directory, the filename scheme
+	// and the prefix of the names that don't follow a known scheme
+	Sequence string
+	ID       string
+	Number   int64
+	Time     time.Time
+	// Inferred is true when Time is out of sequence and InferredTime has been estimated
+	// from the neighbours
+	Inferred     bool
+	InferredTime time.Time
+	// OutOfSequence is true when Time is reset or far from the order of the neighbours
+	OutOfSequence bool
+}
+
+var (
+	reSequenceNumber = regexp.MustCompile(`^\d+`)
+
+	// Dates before this year are the reset value of the camera clock
+	ResetYear = 2001
+	// Maximum time between the neighbours to estimate the time of a media between them
+	MaxInferGap = 4 * time.Hour
+	// Minimum difference with the time expected from the neighbours to flag a valid date as out of sequence
+	MaxSequenceDeviation = time.Hour
+	// InferOutOfSequence replaces also the valid dates out of sequence, by default only the reset dates are replaced
+	InferOutOfSequence bool
+)
+
+// FileSequenceID returns the ID of the media from its filename
+func FileSequenceID(filename string) (string, bool) {
+	name, ok := ParseFileName(filename)
+
+	return name.ID, ok
+}
+
+// NewSequenceItem returns the item of the media, false if the filename has no sequence number
+func NewSequenceItem(filename string, t time.Time) (SequenceItem, bool) {
+	name, ok := ParseFileName(filename)
+	if !ok {
+		return SequenceItem{}, false
+	}
+
+	sequence := filepath.Dir(filename) + "\x00" + name.Scheme
+	if name.Scheme == "generic" {
+		base := filepath.Base(filename)
+		if i := strings.Index(base, name.ID); i >= 0 {
+			sequence += "\x00" + base[:i]
+		}
+	}
+
+	return SequenceItem{Path: filename, Sequence: sequence, ID: name.ID, Number: name.Number, Time: t}, true
+}
+
+// DateIsReset checks if the date is missing or is the reset value of the camera clock
+func DateIsReset(t time.Time) bool {
+	return t.IsZero() || t.Year() < ResetYear
+}
+
+// InferSequenceTimes sorts the items by sequence and number, marks the items whose time is reset
+// or far from the time expected from their neighbours and estimates the time of the reset items,
+// or of all of them with InferOutOfSequence, interpolating between the closest neighbours with a
+// time in sequence
+func InferSequenceTimes(items []SequenceItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Sequence != items[j].Sequence {
+			return items[i].Sequence < items[j].Sequence
+		}
+		if items[i].Number != items[j].Number {
+			return items[i].Number < items[j].Number
+		}
+		return items[i].Path < items[j].Path
+	})
+
+	for start := 0; start < len(items); {
+		end := start + 1
+		for end < len(items) && items[end].Sequence == items[start].Sequence {
+			end++
+		}
+
+		inferSequence(items[start:end])
+		start = end
+	}
+}
+
+// inferSequence marks and infers the times of the items of a sequence sorted by number
+func inferSequence(items []SequenceItem) {
+	inSequence := longestSequence(items)
+
+	prev := -1
+	for i := range items {
+		if inSequence[i] {
+			prev = i
+			continue
+		}
+
+		next := i + 1
+		for next < len(items) && !inSequence[next] {
+			next++
+		}
+
+		reset := DateIsReset(items[i].Time)
+		if !reset && !deviates(items, i, prev, next) {
+			continue
+		}
+
+		items[i].OutOfSequence = true
+
+		if prev < 0 || next >= len(items) || (!reset && !InferOutOfSequence) {
+			continue
+		}
+
+		gap := items[next].Time.Sub(items[prev].Time)
+		if gap > MaxInferGap {
+			continue
+		}
+
+		items[i].Inferred = true
+		items[i].InferredTime = items[prev].Time.Add(time.Duration(float64(gap) * numberFraction(items, i, prev, next)))
+	}
+}
+
+// deviates checks if the time of the item is more than MaxSequenceDeviation from the time expected
+// from the neighbours in sequence, prev and next are out of range when there is no neighbour
+func deviates(items []SequenceItem, i int, prev int, next int) bool {
+	var expected time.Time
+	switch {
+	case prev >= 0 && next < len(items):
+		gap := items[next].Time.Sub(items[prev].Time)
+		expected = items[prev].Time.Add(time.Duration(float64(gap) * numberFraction(items, i, prev, next)))
+	case prev >= 0:
+		expected = items[prev].Time
+	case next < len(items):
+		expected = items[next].Time
+	default:
+		return false
+	}
+
+	return items[i].Time.Sub(expected).Abs() > MaxSequenceDeviation
+}
+
+// numberFraction returns the position of the number of the item between the numbers of prev and next
+func numberFraction(items []SequenceItem, i int, prev int, next int) float64 {
+	if items[next].Number == items[prev].Number {
+		return 0
+	}
+
+	return float64(items[i].Number-items[prev].Number) / float64(items[next].Number-items[prev].Number)
+}
+
+// longestSequence returns the items that belong to the longest sequence of valid times in
+// chronological order
+func longestSequence(items []SequenceItem) []bool {
+	// tails[k] is the index of the item that ends the best sequence of length k+1
+	var tails []int
+	parent := make([]int, len(items))
+
+	for i, item := range items {
+		parent[i] = -1
+		if DateIsReset(item.Time) {
+			continue
+		}
+
+		k := sort.Search(len(tails), func(k int) bool {
+			return items[tails[k]].Time.After(item.Time)
+		})
+		if k > 0 {
+			parent[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	inSequence := make([]bool, len(items))
+	if len(tails) == 0 {
+		return inSequence
+	}
+
+	for i := tails[len(tails)-1]; i >= 0; i = parent[i] {
+		inSequence[i] = true
+	}
+
+	return inSequence
+}
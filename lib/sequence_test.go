@@ -0,0 +1,138 @@
+package syncmediatrack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileSequenceID(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"photos/IMG_1234.JPG", "1234"},
+		{"GX011234.MP4", "1234"},
+		{"DSC01234.ARW", "01234"},
+	}
+
+	for _, tt := range tests {
+		id, ok := FileSequenceID(tt.filename)
+		if !ok || id != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.filename, tt.expected, id)
+		}
+	}
+}
+
+func TestInferSequenceTimes(t *testing.T) {
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	reset := time.Date(2000, 1, 1, 0, 0, 5, 0, time.UTC)
+
+	times := map[string]time.Time{
+		"IMG_0001.JPG": start,
+		"IMG_0002.JPG": start.Add(time.Minute),
+		"IMG_0003.JPG": reset,
+		"IMG_0004.JPG": time.Time{},
+		"IMG_0005.JPG": start.Add(4 * time.Minute),
+		"IMG_0006.JPG": start.Add(-48 * time.Hour),
+		"IMG_0007.JPG": start.Add(6 * time.Minute),
+		"IMG_0008.JPG": reset,
+	}
+
+	tests := []struct {
+		name     string
+		infer    bool
+		expected map[string]time.Time
+	}{
+		{"reset dates", false, map[string]time.Time{
+			"0003": start.Add(2 * time.Minute),
+			"0004": start.Add(3 * time.Minute),
+		}},
+		{"out of sequence dates", true, map[string]time.Time{
+			"0003": start.Add(2 * time.Minute),
+			"0004": start.Add(3 * time.Minute),
+			"0006": start.Add(5 * time.Minute),
+		}},
+	}
+
+	defer func() { InferOutOfSequence = false }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InferOutOfSequence = tt.infer
+
+			var items []SequenceItem
+			for filename, date := range times {
+				item, ok := NewSequenceItem(filename, date)
+				if !ok {
+					t.Fatalf("%s has no sequence number", filename)
+				}
+				items = append(items, item)
+			}
+
+			InferSequenceTimes(items)
+
+			for _, item := range items {
+				inferred, ok := tt.expected[item.ID]
+				switch {
+				case ok && (!item.Inferred || !item.InferredTime.Equal(inferred)):
+					t.Errorf("%s: expected %v, got %v", item.ID, inferred, item.InferredTime)
+				case !ok && item.Inferred:
+					t.Errorf("%s: unexpected inferred time %v", item.ID, item.InferredTime)
+				}
+
+				outOfSequence := item.ID == "0003" || item.ID == "0004" || item.ID == "0006" || item.ID == "0008"
+				if item.OutOfSequence != outOfSequence {
+					t.Errorf("%s: expected out of sequence %v, got %v", item.ID, outOfSequence, item.OutOfSequence)
+				}
+			}
+
+			// the last reset item has no neighbour after it
+			for _, item := range items {
+				if item.ID == "0008" && item.Inferred {
+					t.Errorf("%s: expected out of sequence without inferred time", item.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestInferSequenceTimesCameras(t *testing.T) {
+	start := time.Date(2024, 1, 28, 10, 0, 0, 0, time.UTC)
+	reset := time.Date(2000, 1, 1, 0, 0, 5, 0, time.UTC)
+
+	// a Canon and a Sony in the same directory, the Sony clock is 3 hours behind and a burst is
+	// a minute out of order, that is not flagged
+	times := map[string]time.Time{
+		"trip/IMG_0100.JPG": start,
+		"trip/IMG_0101.JPG": start.Add(10 * time.Minute),
+		"trip/IMG_0102.JPG": reset,
+		"trip/IMG_0103.JPG": start.Add(30 * time.Minute),
+		"trip/DSC00101.ARW": start.Add(-3*time.Hour + 5*time.Minute),
+		"trip/DSC00102.ARW": start.Add(-3*time.Hour + 4*time.Minute),
+		"trip/DSC00103.ARW": start.Add(-3*time.Hour + 15*time.Minute),
+	}
+
+	var items []SequenceItem
+	for filename, date := range times {
+		item, ok := NewSequenceItem(filename, date)
+		if !ok {
+			t.Fatalf("%s has no sequence number", filename)
+		}
+		items = append(items, item)
+	}
+
+	InferSequenceTimes(items)
+
+	for _, item := range items {
+		switch item.Path {
+		case "trip/IMG_0102.JPG":
+			if !item.Inferred || !item.InferredTime.Equal(start.Add(20*time.Minute)) {
+				t.Errorf("%s: expected %v, got %v", item.Path, start.Add(20*time.Minute), item.InferredTime)
+			}
+		default:
+			if item.OutOfSequence || item.Inferred {
+				t.Errorf("%s: unexpected out of sequence %v", item.Path, item.InferredTime)
+			}
+		}
+	}
+}
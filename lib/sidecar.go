@@ -0,0 +1,103 @@
+package syncmediatrack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/barasher/go-exiftool"
+)
+
+var (
+	// Sidecar writes the GPS position in a XMP sidecar file instead of the media
+	Sidecar bool
+	// SidecarExt names the new sidecars name.ext.xmp as darktable does instead of name.xmp
+	SidecarExt bool
+)
+
+// Empty XMP packet, exiftool adds the tags to it
+const emptyXMP = `<?xpacket begin='` + "\ufeff" + `' id='W5M0MpCehiHzreSzNTczkc9d'?>
+<x:xmpmeta xmlns:x='adobe:ns:meta/'>
+ <rdf:RDF xmlns:rdf='http://www.w3.org/1999/02/22-rdf-syntax-ns#'>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end='w'?>
+`
+
+// sidecarNames returns the names of the sidecars of the media: name.ext.xmp used by darktable
+// and name.xmp used by Lightroom
+func sidecarNames(filename string) []string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	return []string{filename + ".xmp", filename + ".XMP", base + ".xmp", base + ".XMP"}
+}
+
+// FindSidecar returns the existing sidecar of the media
+func FindSidecar(filename string) (string, bool) {
+	for _, name := range sidecarNames(filename) {
+		if f, err := os.Stat(name); err == nil && !f.IsDir() {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// SidecarName returns the existing sidecar of the media or the name of a new one
+func SidecarName(filename string) string {
+	if name, ok := FindSidecar(filename); ok {
+		return name
+	}
+
+	if SidecarExt {
+		return filename + ".xmp"
+	}
+
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".xmp"
+}
+
+// writeSidecarGPS writes the GPS position in the sidecar of the media, it is created if it
+// doesn't exist
+func writeSidecarGPS(gps Trkpt, filename string) error {
+	gpsTime, err := ParseTrkptTime(gps.Time)
+	if err != nil {
+		return fmt.Errorf("invalid GPS time %s: %w", gps.Time, err)
+	}
+
+	et, err := getExiftool()
+	if err != nil {
+		return err
+	}
+
+	sidecar := SidecarName(filename)
+
+	fileInfo := exiftool.EmptyFileMetadata()
+	fileInfo.File = sidecar
+
+	fileInfo.SetFloat("XMP-exif:GPSLatitude", gps.Lat)
+	fileInfo.SetFloat("XMP-exif:GPSLongitude", gps.Lon)
+	fileInfo.SetInt("XMP-exif:GPSAltitude", int64(gps.Ele))
+	fileInfo.SetString("XMP-exif:GPSAltitudeRef", "above sea level")
+	fileInfo.SetString("XMP-exif:GPSDateTime", gpsTime.UTC().Format("2006:01:02 15:04:05Z"))
+
+	return recordChange(sidecar, sidecarGPSTags, func() error {
+		if _, err := os.Stat(sidecar); os.IsNotExist(err) {
+			err = os.WriteFile(sidecar, []byte(emptyXMP), 0o644)
+			if err != nil {
+				return err
+			}
+		}
+
+		fileInfos := []exiftool.FileMetadata{fileInfo}
+		et.WriteMetadata(fileInfos)
+
+		return fileInfos[0].Err
+	})
+}
+
+// GPS tags written in the sidecars
+var sidecarGPSTags = []string{
+	"XMP-exif:GPSLatitude", "XMP-exif:GPSLongitude", "XMP-exif:GPSAltitude", "XMP-exif:GPSAltitudeRef",
+	"XMP-exif:GPSDateTime",
+}
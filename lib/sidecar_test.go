@@ -0,0 +1,43 @@
+package syncmediatrack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSidecarName(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"IMG_0002.CR3.xmp", "IMG_0003.xmp"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(emptyXMP), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filename string
+		ext      bool
+		expected string
+	}{
+		{"IMG_0001.CR3", false, "IMG_0001.xmp"},
+		{"IMG_0001.CR3", true, "IMG_0001.CR3.xmp"},
+		// the existing sidecars are used whatever the convention
+		{"IMG_0002.CR3", false, "IMG_0002.CR3.xmp"},
+		{"IMG_0003.CR3", true, "IMG_0003.xmp"},
+	}
+
+	defer func() { SidecarExt = false }()
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			SidecarExt = tt.ext
+
+			result := SidecarName(filepath.Join(dir, tt.filename))
+			if result != filepath.Join(dir, tt.expected) {
+				t.Errorf("Expected %s, got %s", tt.expected, filepath.Base(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package syncmediatrack
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// OffsetEstimate is a robust estimation of the error of a camera clock
+type OffsetEstimate struct {
+	Offset time.Duration
+	// Spread is the median absolute deviation of the accepted offsets
+	Spread  time.Duration
+	Samples int
+	Inliers int
+	// Confidence goes from 0 to 1, it grows with the proportion of accepted offsets and their number
+	Confidence float64
+}
+
+// Offsets further than this number of scaled median absolute deviations are rejected
+const outlierThreshold = 3
+
+// Number of accepted offsets needed to have full confidence
+const minInliers = 5
+
+// EstimateOffset returns the median of the offsets after rejecting the outliers
+func EstimateOffset(offsets []time.Duration) OffsetEstimate {
+	estimate := OffsetEstimate{Samples: len(offsets)}
+	if len(offsets) == 0 {
+		return estimate
+	}
+
+	values := make([]float64, len(offsets))
+	for i, offset := range offsets {
+		values[i] = offset.Seconds()
+	}
+
+	inliers := rejectOutliers(values)
+	med := median(inliers)
+
+	estimate.Offset = time.Duration(med * float64(time.Second))
+	estimate.Spread = time.Duration(mad(inliers, med) * float64(time.Second))
+	estimate.Inliers = len(inliers)
+	estimate.Confidence = float64(len(inliers)) / float64(len(values)) * math.Min(1, float64(len(inliers))/minInliers)
+
+	return estimate
+}
+
+// rejectOutliers removes the values further than outlierThreshold scaled MADs from the median
+func rejectOutliers(values []float64) []float64 {
+	med := median(values)
+	// scale the MAD to be comparable with the standard deviation of a normal distribution
+	limit := outlierThreshold * 1.4826 * mad(values, med)
+
+	var inliers []float64
+	for _, v := range values {
+		if math.Abs(v-med) <= limit {
+			inliers = append(inliers, v)
+		}
+	}
+
+	return inliers
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// mad returns the median absolute deviation
+func mad(values []float64, med float64) float64 {
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+
+	return median(deviations)
+}
+
+// DriftEstimate is a robust linear fit of the error of a camera clock: Offset at the Reference
+// time plus the error gained every day
+type DriftEstimate struct {
+	Offset    time.Duration
+	Drift     time.Duration
+	Reference time.Time
+	// Residuals are the differences between the offsets and the fit
+	Residuals   []time.Duration
+	MaxResidual time.Duration
+	Spread      time.Duration
+}
+
+// Minimum number of offsets and time span between them to fit the drift of the clock,
+// with less data only a constant offset is estimated
+var (
+	MinDriftSamples = 3
+	MinDriftSpan    = time.Hour
+)
+
+// EstimateDrift fits offset and drift with the Theil-Sen estimator, the slope is the median of
+// the slopes between all the pairs of offsets and the intercept the median of the intercepts
+func EstimateDrift(times []time.Time, offsets []time.Duration) DriftEstimate {
+	var estimate DriftEstimate
+	if len(times) == 0 || len(times) != len(offsets) {
+		return estimate
+	}
+
+	first, last := times[0], times[0]
+	for _, t := range times {
+		if t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	estimate.Reference = first
+
+	x := make([]float64, len(times))
+	y := make([]float64, len(times))
+	for i := range times {
+		x[i] = times[i].Sub(first).Seconds()
+		y[i] = offsets[i].Seconds()
+	}
+
+	slope := 0.0
+	if len(times) >= MinDriftSamples && last.Sub(first) >= MinDriftSpan {
+		var slopes []float64
+		for i := range x {
+			for j := i + 1; j < len(x); j++ {
+				if x[j] != x[i] {
+					slopes = append(slopes, (y[j]-y[i])/(x[j]-x[i]))
+				}
+			}
+		}
+		slope = median(slopes)
+	}
+
+	intercepts := make([]float64, len(x))
+	for i := range x {
+		intercepts[i] = y[i] - slope*x[i]
+	}
+	intercept := median(intercepts)
+
+	estimate.Offset = time.Duration(intercept * float64(time.Second))
+	estimate.Drift = time.Duration(slope * 24 * float64(time.Hour))
+
+	residuals := make([]float64, len(x))
+	for i := range x {
+		residuals[i] = y[i] - (intercept + slope*x[i])
+		residual := time.Duration(residuals[i] * float64(time.Second))
+		estimate.Residuals = append(estimate.Residuals, residual)
+		if math.Abs(residuals[i]) > estimate.MaxResidual.Seconds() {
+			estimate.MaxResidual = time.Duration(math.Abs(residuals[i]) * float64(time.Second))
+		}
+	}
+	estimate.Spread = time.Duration(mad(residuals, 0) * float64(time.Second))
+
+	return estimate
+}
+
+// At returns the error of the camera clock at the given time
+func (d DriftEstimate) At(t time.Time) time.Duration {
+	days := t.Sub(d.Reference).Hours() / 24
+
+	return d.Offset + time.Duration(float64(d.Drift)*days)
+}
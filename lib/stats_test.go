@@ -0,0 +1,88 @@
+package syncmediatrack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateOffset(t *testing.T) {
+	t.Run("outliers", func(t *testing.T) {
+		offsets := []time.Duration{
+			200 * time.Second,
+			198 * time.Second,
+			203 * time.Second,
+			201 * time.Second,
+			199 * time.Second,
+			-3600 * time.Second,
+			4000 * time.Second,
+		}
+
+		result := EstimateOffset(offsets)
+		if result.Offset != 200*time.Second {
+			t.Errorf("Expected offset %v, got %v", 200*time.Second, result.Offset)
+		}
+		if result.Samples != 7 || result.Inliers != 5 {
+			t.Errorf("Expected 5 of 7 inliers, got %d of %d", result.Inliers, result.Samples)
+		}
+		if result.Confidence <= 0.7 || result.Confidence >= 0.72 {
+			t.Errorf("Unexpected confidence %v", result.Confidence)
+		}
+	})
+
+	t.Run("few samples", func(t *testing.T) {
+		result := EstimateOffset([]time.Duration{10 * time.Second, 12 * time.Second})
+		if result.Offset != 11*time.Second {
+			t.Errorf("Expected offset %v, got %v", 11*time.Second, result.Offset)
+		}
+		if result.Confidence != 0.4 {
+			t.Errorf("Expected confidence 0.4, got %v", result.Confidence)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		result := EstimateOffset(nil)
+		if result.Samples != 0 || result.Confidence != 0 {
+			t.Errorf("Unexpected estimate %+v", result)
+		}
+	})
+}
+
+func TestEstimateDrift(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("drift", func(t *testing.T) {
+		// 10s at the start and 4s more every day, with an outlier
+		var times []time.Time
+		var offsets []time.Duration
+		for day := 0; day < 10; day++ {
+			times = append(times, start.AddDate(0, 0, day))
+			offsets = append(offsets, time.Duration(10+4*day)*time.Second)
+		}
+		times = append(times, start.AddDate(0, 0, 3).Add(time.Hour))
+		offsets = append(offsets, time.Hour)
+
+		result := EstimateDrift(times, offsets)
+		if result.Offset != 10*time.Second || result.Drift != 4*time.Second {
+			t.Errorf("Expected offset 10s and drift 4s, got %v and %v", result.Offset, result.Drift)
+		}
+
+		expected := 30 * time.Second
+		if at := result.At(start.AddDate(0, 0, 5)); at != expected {
+			t.Errorf("Expected %v, got %v", expected, at)
+		}
+
+		if result.Spread != 0 || result.MaxResidual < 59*time.Minute {
+			t.Errorf("Unexpected residuals %v", result.Residuals)
+		}
+	})
+
+	t.Run("short span", func(t *testing.T) {
+		times := []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute)}
+		offsets := []time.Duration{5 * time.Second, 6 * time.Second, 7 * time.Second}
+
+		result := EstimateDrift(times, offsets)
+		if result.Drift != 0 || result.Offset != 6*time.Second {
+			t.Errorf("Expected constant offset 6s, got %v and drift %v", result.Offset, result.Drift)
+		}
+	})
+}
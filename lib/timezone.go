@@ -0,0 +1,187 @@
+package syncmediatrack
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ZoneMatch is an interpretation of a time without time zone that agrees with the track, the
+// track point at Time is in the time zone Zone
+type ZoneMatch struct {
+	Zone string
+	Time time.Time
+	// Diff is the time between Time and the closest track point
+	Diff time.Duration
+}
+
+// ZoneGuess has the interpretations of a time without time zone that agree with the track
+type ZoneGuess struct {
+	Matches []ZoneMatch
+}
+
+// Zones whose offset is the same give the same instant, they are not ambiguous
+func (g ZoneGuess) instants() []time.Time {
+	var instants []time.Time
+	for _, match := range g.Matches {
+		if !slices.ContainsFunc(instants, match.Time.Equal) {
+			instants = append(instants, match.Time)
+		}
+	}
+
+	return instants
+}
+
+// Found checks if there is a unique instant that agrees with the track
+func (g ZoneGuess) Found() bool {
+	return len(g.instants()) == 1
+}
+
+// Ambiguous checks if several instants agree with the track, as the repeated hour when the
+// summer time ends or the medias taken near the border of two time zones
+func (g ZoneGuess) Ambiguous() bool {
+	return len(g.instants()) > 1
+}
+
+// Largest difference between the local time and UTC
+const maxZoneOffset = 14 * time.Hour
+
+var (
+	// Time zones of the positions rounded to 0.01 degrees
+	zoneCache      = map[[2]float64][]string{}
+	zoneCacheMutex sync.Mutex
+)
+
+// GuessTimeZone interprets a time without time zone in the time zones of the track positions
+// around it and returns the time zones where the instant has a track point in the same time zone
+func GuessTimeZone(wall time.Time) ZoneGuess {
+	var guess ZoneGuess
+
+	for _, zone := range trackZones(wall) {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			continue
+		}
+
+		for _, t := range wallTimeInstants(wall, loc) {
+			var closest Trkpt
+			diff, ok := closestTrackTime(t, &closest)
+			if !ok || diff > MaxTime*time.Second {
+				continue
+			}
+
+			if !slices.Contains(pointZones(closest), zone) {
+				continue
+			}
+
+			guess.Matches = append(guess.Matches, ZoneMatch{Zone: zone, Time: t.In(loc), Diff: diff})
+		}
+	}
+
+	sort.Slice(guess.Matches, func(i, j int) bool {
+		if !guess.Matches[i].Time.Equal(guess.Matches[j].Time) {
+			return guess.Matches[i].Time.Before(guess.Matches[j].Time)
+		}
+		return guess.Matches[i].Zone < guess.Matches[j].Zone
+	})
+
+	return guess
+}
+
+// wallTimeInstants returns the instants when the clocks of the location show the wall time: none
+// in the hour skipped when the summer time starts and two in the hour repeated when it ends
+func wallTimeInstants(wall time.Time, loc *time.Location) []time.Time {
+	utc := wallTime(wall, time.UTC)
+
+	var instants []time.Time
+	var offsets []int
+	// the offsets of the location the day before and after cover any change of time
+	for _, around := range []time.Time{utc.Add(-24 * time.Hour), utc, utc.Add(24 * time.Hour)} {
+		_, offset := around.In(loc).Zone()
+		if slices.Contains(offsets, offset) {
+			continue
+		}
+		offsets = append(offsets, offset)
+
+		t := utc.Add(-time.Duration(offset) * time.Second)
+		if _, actual := t.In(loc).Zone(); actual == offset {
+			instants = append(instants, t)
+		}
+	}
+
+	sort.Slice(instants, func(i, j int) bool {
+		return instants[i].Before(instants[j])
+	})
+
+	return instants
+}
+
+// trackZones returns the time zones of the track positions that could have the wall time
+func trackZones(wall time.Time) []string {
+	utc := wallTime(wall, time.UTC)
+	window := maxZoneOffset + MaxTime*time.Second
+
+	var zones []string
+	for _, gpx := range DataGPX {
+		for _, trkpt := range gpx.Trk.Trkseg.Trkpt {
+			t := GetTimeFromTrkpt(trkpt)
+			if t.IsZero() || t.Sub(utc).Abs() > window {
+				continue
+			}
+
+			for _, zone := range pointZones(trkpt) {
+				if !slices.Contains(zones, zone) {
+					zones = append(zones, zone)
+				}
+			}
+		}
+	}
+
+	sort.Strings(zones)
+
+	return zones
+}
+
+// pointZones returns the time zones of the track point, near a border there are several
+func pointZones(trkpt Trkpt) []string {
+	key := [2]float64{math.Round(trkpt.Lat*100) / 100, math.Round(trkpt.Lon*100) / 100}
+
+	zoneCacheMutex.Lock()
+	defer zoneCacheMutex.Unlock()
+
+	if zones, ok := zoneCache[key]; ok {
+		return zones
+	}
+
+	zones, err := finder.GetTimezoneNames(trkpt.Lon, trkpt.Lat)
+	if err != nil {
+		zones = nil
+	}
+	zoneCache[key] = zones
+
+	return zones
+}
+
+// closestTrackTime returns the track point closest in time and the time between them
+func closestTrackTime(t time.Time, closestPoint *Trkpt) (time.Duration, bool) {
+	closest := time.Duration(-1)
+
+	for _, gpx := range DataGPX {
+		for _, trkpt := range gpx.Trk.Trkseg.Trkpt {
+			trkptTime := GetTimeFromTrkpt(trkpt)
+			if trkptTime.IsZero() {
+				continue
+			}
+
+			diff := t.Sub(trkptTime).Abs()
+			if closest < 0 || diff < closest {
+				*closestPoint = trkpt
+				closest = diff
+			}
+		}
+	}
+
+	return closest, closest >= 0
+}
@@ -0,0 +1,88 @@
+package syncmediatrack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWallTimeInstants(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name     string
+		wall     time.Time
+		expected []string
+	}{
+		{"winter", time.Date(2023, 1, 15, 10, 0, 0, 0, time.UTC), []string{"2023-01-15T09:00:00Z"}},
+		{"summer", time.Date(2023, 7, 15, 10, 0, 0, 0, time.UTC), []string{"2023-07-15T08:00:00Z"}},
+		{"skipped hour", time.Date(2023, 3, 26, 2, 30, 0, 0, time.UTC), nil},
+		{"repeated hour", time.Date(2023, 10, 29, 2, 30, 0, 0, time.UTC), []string{"2023-10-29T00:30:00Z", "2023-10-29T01:30:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instants := wallTimeInstants(tt.wall, madrid)
+			if len(instants) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, instants)
+			}
+
+			for i, instant := range instants {
+				if instant.UTC().Format(time.RFC3339) != tt.expected[i] {
+					t.Errorf("Expected %v, got %v", tt.expected[i], instant.UTC().Format(time.RFC3339))
+				}
+			}
+		})
+	}
+}
+
+func TestGuessTimeZone(t *testing.T) {
+	defer func(data map[string]Gpx) { DataGPX = data }(DataGPX)
+
+	// a track in Madrid every minute
+	track := func(start time.Time, minutes int) {
+		var gpx Gpx
+		for i := 0; i <= minutes; i++ {
+			gpx.Trk.Trkseg.Trkpt = append(gpx.Trk.Trkseg.Trkpt, Trkpt{
+				Lat:  40.4168,
+				Lon:  -3.7038,
+				Time: start.Add(time.Duration(i) * time.Minute).Format("2006-01-02T15:04:05Z"),
+			})
+		}
+		DataGPX = map[string]Gpx{"madrid.gpx": gpx}
+	}
+
+	t.Run("summer", func(t *testing.T) {
+		track(time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC), 60)
+
+		guess := GuessTimeZone(time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC))
+		if !guess.Found() || guess.Matches[0].Zone != "Europe/Madrid" || !guess.Matches[0].Time.Equal(time.Date(2023, 7, 1, 10, 30, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected guess %+v", guess)
+		}
+
+		guess = GuessTimeZone(time.Date(2023, 7, 1, 20, 0, 0, 0, time.UTC))
+		if guess.Found() || guess.Ambiguous() {
+			t.Errorf("Expected no match, got %+v", guess)
+		}
+	})
+
+	t.Run("repeated hour", func(t *testing.T) {
+		track(time.Date(2023, 10, 29, 0, 0, 0, 0, time.UTC), 120)
+
+		guess := GuessTimeZone(time.Date(2023, 10, 29, 2, 30, 0, 0, time.UTC))
+		if !guess.Ambiguous() || len(guess.Matches) != 2 {
+			t.Errorf("Expected an ambiguous guess, got %+v", guess)
+		}
+	})
+
+	t.Run("repeated hour out of the track", func(t *testing.T) {
+		track(time.Date(2023, 10, 29, 0, 0, 0, 0, time.UTC), 45)
+
+		guess := GuessTimeZone(time.Date(2023, 10, 29, 2, 30, 0, 0, time.UTC))
+		if !guess.Found() || !guess.Matches[0].Time.Equal(time.Date(2023, 10, 29, 0, 30, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected guess %+v", guess)
+		}
+	})
+}